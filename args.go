@@ -0,0 +1,73 @@
+package confkey
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ParseArgs sets values on target from command line style arguments, both
+// --key=value and --key value forms are supported where key is a confkey.
+// Boolean keys may be given as a bare --key meaning true.
+//
+// Arguments that do not match any confkey, and everything after a bare --,
+// are returned in the remainder
+func ParseArgs(target interface{}, args []string) ([]string, error) {
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return nil, errors.New("pointer is required")
+	}
+
+	remainder := []string{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			remainder = append(remainder, args[i+1:]...)
+			break
+		}
+
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			remainder = append(remainder, arg)
+			continue
+		}
+
+		key := strings.TrimPrefix(arg, "--")
+		value := ""
+		hasValue := false
+
+		if idx := strings.Index(key, "="); idx > -1 {
+			value = key[idx+1:]
+			key = key[:idx]
+			hasValue = true
+		}
+
+		// resolved like SetStructFieldWithKey so dotted keys and pointer fields are found
+		kind, err := KindForKey(target, key)
+		if err != nil {
+			remainder = append(remainder, arg)
+			continue
+		}
+
+		if !hasValue {
+			if kind == reflect.Bool {
+				value = "true"
+			} else {
+				if i+1 >= len(args) {
+					return remainder, fmt.Errorf("%s: a value is required", key)
+				}
+
+				i++
+				value = args[i]
+			}
+		}
+
+		err = SetStructFieldWithKey(target, key, value)
+		if err != nil {
+			return remainder, fmt.Errorf("%s: %s", key, err)
+		}
+	}
+
+	return remainder, nil
+}
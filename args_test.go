@@ -0,0 +1,72 @@
+package confkey
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseArgs", func() {
+	var d TestData
+
+	BeforeEach(func() {
+		d = TestData{}
+	})
+
+	It("Should require a pointer", func() {
+		_, err := ParseArgs(d, []string{})
+		Expect(err).To(MatchError("pointer is required"))
+	})
+
+	It("Should support both argument forms", func() {
+		rest, err := ParseArgs(&d, []string{"--loglevel=info", "--int", "10", "--comma_split", "a,b"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rest).To(BeEmpty())
+		Expect(d.StringEnum).To(Equal("info"))
+		Expect(d.Int).To(Equal(10))
+		Expect(d.CommaSplit).To(Equal([]string{"a", "b"}))
+	})
+
+	It("Should support bare booleans", func() {
+		rest, err := ParseArgs(&d, []string{"--bool", "file.txt"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rest).To(Equal([]string{"file.txt"}))
+		Expect(d.Bool).To(BeTrue())
+
+		_, err = ParseArgs(&d, []string{"--bool=false"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.Bool).To(BeFalse())
+	})
+
+	It("Should support nested keys and pointer fields", func() {
+		n := NestedData{}
+		rest, err := ParseArgs(&n, []string{"--tls.ca=/x", "--tls.mode", "none"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rest).To(BeEmpty())
+		Expect(n.TLS).To(Equal(NestedTLS{CA: "/x", Mode: "none"}))
+
+		p := PointerData{}
+		rest, err = ParseArgs(&p, []string{"--enabled", "file.txt"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rest).To(Equal([]string{"file.txt"}))
+		Expect(*p.Enabled).To(BeTrue())
+
+		_, err = ParseArgs(&p, []string{"--disabled"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*p.Enabled).To(BeFalse())
+	})
+
+	It("Should return unknown arguments", func() {
+		rest, err := ParseArgs(&d, []string{"-v", "--unknown=1", "--int=1", "cmd", "--", "--int=2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rest).To(Equal([]string{"-v", "--unknown=1", "cmd", "--int=2"}))
+		Expect(d.Int).To(Equal(1))
+	})
+
+	It("Should report conversion errors with the key", func() {
+		_, err := ParseArgs(&d, []string{"--int=one"})
+		Expect(err).To(MatchError(`int: strconv.ParseInt: parsing "one": invalid syntax`))
+
+		_, err = ParseArgs(&d, []string{"--int"})
+		Expect(err).To(MatchError("int: a value is required"))
+	})
+})
@@ -0,0 +1,78 @@
+package confkey
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// typeInfo is the confkey metadata of a struct type, it is built once per type and cached
+type typeInfo struct {
+	// fields are the fields of the struct including reachable promoted ones, see structFields
+	fields []reflect.StructField
+
+	// keys maps confkeys to the name of the field they are declared on
+	keys map[string]string
+
+	// aliases maps aliases, without any leading !, to the name of the field they are declared on
+	aliases map[string]string
+
+	// tags maps field names to their tags
+	tags map[string]reflect.StructTag
+}
+
+var (
+	typeCache = make(map[reflect.Type]*typeInfo)
+	cacheMu   sync.RWMutex
+)
+
+// cachedType retrieves the metadata for the struct type st, building it on first use
+func cachedType(st reflect.Type) *typeInfo {
+	cacheMu.RLock()
+	ti, ok := typeCache[st]
+	cacheMu.RUnlock()
+
+	if ok {
+		return ti
+	}
+
+	ti = newTypeInfo(st)
+
+	cacheMu.Lock()
+	typeCache[st] = ti
+	cacheMu.Unlock()
+
+	return ti
+}
+
+func newTypeInfo(st reflect.Type) *typeInfo {
+	ti := &typeInfo{
+		fields:  structFields(st),
+		keys:    make(map[string]string),
+		aliases: make(map[string]string),
+		tags:    make(map[string]reflect.StructTag),
+	}
+
+	// the first field declaring a key wins, just like a scan of the fields would
+	for _, field := range ti.fields {
+		ti.tags[field.Name] = field.Tag
+
+		if key, ok := confkeyTag(field); ok {
+			if _, found := ti.keys[key]; !found {
+				ti.keys[key] = field.Name
+			}
+		}
+
+		if aliases, ok := field.Tag.Lookup("aliases"); ok {
+			for _, alias := range strings.Split(aliases, ",") {
+				alias = strings.TrimPrefix(strings.TrimSpace(alias), "!")
+
+				if _, found := ti.aliases[alias]; !found {
+					ti.aliases[alias] = field.Name
+				}
+			}
+		}
+	}
+
+	return ti
+}
@@ -0,0 +1,50 @@
+package confkey
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	It("Should cache the type metadata", func() {
+		st := reflect.TypeOf(AliasData{})
+
+		ti := cachedType(st)
+		Expect(cachedType(st)).To(BeIdenticalTo(ti))
+		Expect(ti.keys).To(Equal(map[string]string{"feature": "Feature", "name": "Name"}))
+		Expect(ti.aliases).To(Equal(map[string]string{"enable_feature": "Feature", "no_feature": "Feature", "title": "Name", "untitled": "Name"}))
+		Expect(ti.tags["Feature"].Get("environment")).To(Equal("ALIAS_FEATURE"))
+	})
+})
+
+func BenchmarkFieldWithKey(b *testing.B) {
+	d := &TestData{}
+
+	for i := 0; i < b.N; i++ {
+		fieldWithKey(d, "interval")
+	}
+}
+
+// BenchmarkFieldWithKeyUncached builds the metadata on every lookup, as was done before it was cached
+func BenchmarkFieldWithKeyUncached(b *testing.B) {
+	st := reflect.TypeOf(TestData{})
+
+	for i := 0; i < b.N; i++ {
+		_ = newTypeInfo(st).keys["interval"]
+	}
+}
+
+func BenchmarkSetStructFieldWithKey(b *testing.B) {
+	d := &TestData{}
+
+	for i := 0; i < b.N; i++ {
+		err := SetStructFieldWithKey(d, "int", fmt.Sprintf("%d", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
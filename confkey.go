@@ -31,11 +31,16 @@ package confkey
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
 	"os"
+	"os/user"
 	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -44,256 +49,2149 @@ import (
 	validator "github.com/choria-io/go-validator"
 )
 
-// Validate validates the struct
+// Setter is implemented by types that parse their own configuration values, when the
+// field implements it SetConfkey is called in place of the built in conversions
+type Setter interface {
+	SetConfkey(string) error
+}
+
+// typeTags are the type tags understood for every kind of field
+var typeTags = map[reflect.Kind][]string{
+	reflect.Slice:  {"split", "comma_split", "colon_split", "semicolon_split", "path_split", "line_split", "filepath"},
+	reflect.Int:    {"bytes"},
+	reflect.Int64:  {"duration", "bytes"},
+	reflect.String: {"title_string", "path_string", "upper_string", "lower_string", "filepath", "url"},
+	reflect.Map:    {"kv_split"},
+	reflect.Struct: {"inline", "time", "url"},
+}
+
+// ErrUnknownKey is returned when a key does not match any confkey of a struct, test for it using errors.Is
+var ErrUnknownKey = errors.New("can't find any structure element configured with confkey")
+
+// Validate validates the struct, nested and embedded structs are validated
+// too with errors in nested structs reported using their dotted key, validators
+// registered using RegisterStructValidator run once all the fields are valid
 func Validate(target interface{}) error {
-	_, err := validator.ValidateStruct(target)
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	errs := validateValue(val, "", false)
+	if len(errs) == 0 {
+		errs = structValidate(target, false)
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// ValidateAll validates the struct like Validate but does not stop at the first
+// failure, every error found is returned and includes the confkey it relates to
+func ValidateAll(target interface{}) []error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	return append(validateValue(val, "", true), structValidate(target, true)...)
+}
+
+// validateValue validates every field of a struct, recursing into nested structs,
+// when all is false it stops at the first error
+func validateValue(val reflect.Value, prefix string, all bool) []error {
+	errs := []error{}
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		// unexported fields like a sync.Mutex can not be inspected
+		if field.PkgPath != "" {
+			continue
+		}
+
+		key, tagged := confkeyTag(field)
+		if !tagged {
+			key = field.Name
+		}
+
+		// nested structs are validated like walkFields visits them, ignoring untagged ones
+		if val.Field(i).Kind() == reflect.Struct && !isLeafStruct(val.Field(i).Type()) {
+			if !tagged && !field.Anonymous {
+				continue
+			}
+
+			nested := prefix
+			if !field.Anonymous {
+				nested = prefix + key + "."
+			}
+
+			errs = append(errs, validateValue(val.Field(i), nested, all)...)
+			if !all && len(errs) > 0 {
+				return errs
+			}
+
+			continue
+		}
+
+		_, err := validator.ValidateStructField(val.Interface(), field.Name)
+		if err != nil {
+			if prefix != "" || all {
+				err = fmt.Errorf("%s%s: %s", prefix, key, err)
+			}
+
+			errs = append(errs, err)
+			if !all {
+				return errs
+			}
+		}
+	}
+
+	checks := []func(interface{}) error{
+		validateRequired,
+		validateMutex,
+		validateRequiredIf,
+		validateMatch,
+		validateWritable,
+		validateDynamicEnums,
+	}
+
+	for _, check := range checks {
+		err := check(val.Interface())
+		if err != nil {
+			if prefix != "" {
+				err = fmt.Errorf("%s: %s", strings.TrimSuffix(prefix, "."), err)
+			}
+
+			errs = append(errs, err)
+			if !all {
+				return errs
+			}
+		}
+	}
+
+	return errs
+}
+
+// ValidateUnique checks that the field tagged with key holds a distinct value in every
+// struct found in slice, this is useful to validate repeated config blocks
+func ValidateUnique(slice interface{}, key string) error {
+	val := reflect.ValueOf(slice)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return errors.New("slice is required")
+	}
+
+	seen := make(map[string]struct{})
+
+	for i := 0; i < val.Len(); i++ {
+		elem := val.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+
+		if elem.Kind() != reflect.Struct {
+			return errors.New("slice of structs is required")
+		}
+
+		item, err := fieldWithKey(elem.Interface(), key)
+		if err != nil {
+			return err
+		}
+
+		v := fmt.Sprint(elem.FieldByName(item).Interface())
+
+		if _, ok := seen[v]; ok {
+			return fmt.Errorf("duplicate value '%s' found for key '%s'", v, key)
+		}
+
+		seen[v] = struct{}{}
+	}
+
+	return nil
+}
+
+// validateMutex ensures that at most one field in every mutex group is set
+func validateMutex(target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	st := val.Type()
+	groups := make(map[string][]string)
+	order := []string{}
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		group, ok := field.Tag.Lookup("mutex")
+		if !ok || group == "" {
+			continue
+		}
+
+		key, ok := confkeyTag(field)
+		if !ok {
+			key = field.Name
+		}
+
+		if isZero(val.Field(i)) {
+			continue
+		}
+
+		if _, ok := groups[group]; !ok {
+			order = append(order, group)
+		}
+
+		groups[group] = append(groups[group], key)
+	}
+
+	for _, group := range order {
+		if len(groups[group]) > 1 {
+			return fmt.Errorf("only one of the keys in mutex group '%s' may be set, found %s", group, strings.Join(groups[group], ", "))
+		}
+	}
+
+	return nil
+}
+
+// validateRequired ensures fields tagged required:"true" are not left at their zero value
+func validateRequired(target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		if field.Tag.Get("required") != "true" || !isZero(val.Field(i)) {
+			continue
+		}
+
+		key, ok := confkeyTag(field)
+		if !ok {
+			key = field.Name
+		}
+
+		return fmt.Errorf("%s is required", key)
+	}
+
+	return nil
+}
+
+// validateRequiredIf ensures fields tagged like required_if:"mode=server" are set when
+// the field with the confkey mode holds the value server
+func validateRequiredIf(target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		cond, ok := field.Tag.Lookup("required_if")
+		if !ok {
+			continue
+		}
+
+		key, ok := confkeyTag(field)
+		if !ok {
+			key = field.Name
+		}
+
+		parts := strings.SplitN(cond, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid required_if '%s' for key '%s', expected key=value", cond, key)
+		}
+
+		other, err := fieldWithKey(val.Interface(), parts[0])
+		if err != nil {
+			return err
+		}
+
+		osf, _ := st.FieldByName(other)
+		current, err := marshalValue(val.FieldByName(other), osf)
+		if err != nil {
+			return err
+		}
+
+		if current == parts[1] && isZero(val.Field(i)) {
+			return fmt.Errorf("%s is required when %s is %s", key, parts[0], parts[1])
+		}
+	}
+
+	return nil
+}
+
+// validateMatch ensures string fields tagged with match hold a value matching the pattern
+func validateMatch(target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		pattern, ok := field.Tag.Lookup("match")
+		if !ok || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		key, ok := confkeyTag(field)
+		if !ok {
+			key = field.Name
+		}
+
+		err := checkMatch(key, pattern, val.Field(i).String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateWritable ensures string fields tagged validate:"writable" hold a directory the
+// process can write to, failures are passed to the function set using SetWritableWarner
+// rather than failing validation when one is set
+func validateWritable(target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		if field.Tag.Get("validate") != "writable" || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		err := checkWritable(val.Field(i).String())
+		if err != nil {
+			err = fmt.Errorf("%s writable validation failed: %s", field.Name, err)
+
+			if warner, ok := writableWarner(); ok {
+				warner(err)
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkWritable checks that dir is a directory by writing a temporary file to it
+func checkWritable(dir string) error {
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	f, err := ioutil.TempFile(dir, ".confkey-writable")
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// checkMatch checks value against a pattern from a match tag
+func checkMatch(key string, pattern string, value string) error {
+	re, err := compiledPattern(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid match pattern for key '%s': %s", key, err)
+	}
+
+	if !re.MatchString(value) {
+		return fmt.Errorf("%s value '%s' does not match %s", key, value, pattern)
+	}
+
+	return nil
+}
+
+// isZero determines if v holds the zero value for its type
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// SetStructDefaults extract defaults out of the tags and set them to the key
+func SetStructDefaults(target interface{}) error {
+	return setStructDefaults(target, false, "")
+}
+
+// SetStructDefaultsWithPrefix extract defaults out of the tags and set them to the key
+// only for keys starting with prefix, like tls. to reinitialize just the TLS settings
+func SetStructDefaultsWithPrefix(target interface{}, prefix string) error {
+	return setStructDefaults(target, false, prefix)
+}
+
+// SetStructDefaultsIfUnset extract defaults out of the tags and set them to the key
+// only when the field still holds its zero value, fields already set are left as is
+func SetStructDefaultsIfUnset(target interface{}) error {
+	return setStructDefaults(target, true, "")
+}
+
+// SetStructDefaultsAndValidate extract defaults out of the tags and set them to the key,
+// then validates target so any default violating its own validate rule is reported
+func SetStructDefaultsAndValidate(target interface{}) error {
+	err := SetStructDefaults(target)
+	if err != nil {
+		return err
+	}
+
+	return Validate(target)
+}
+
+// ResetDefaults zeroes every confkey field of target and then applies the defaults,
+// unlike SetStructDefaults this also clears fields that have no default
+func ResetDefaults(target interface{}) error {
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	err := walkFields(reflect.ValueOf(target).Elem(), "", func(_ string, _ reflect.StructField, v reflect.Value) error {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return SetStructDefaults(target)
+}
+
+func setStructDefaults(target interface{}, onlyUnset bool, prefix string) error {
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	// a key set more than once sets the field declared first, later ones are shadowed
+	seen := make(map[string]struct{})
+
+	return walkFields(reflect.ValueOf(target).Elem(), "", func(key string, field reflect.StructField, v reflect.Value) error {
+		if _, ok := seen[key]; ok {
+			return nil
+		}
+		seen[key] = struct{}{}
+
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		if onlyUnset && !isZero(v) {
+			return nil
+		}
+
+		if value, ok := field.Tag.Lookup("default"); ok {
+			return setDefault(target, field, key, value)
+		}
+
+		// fields without a default are still initialized from their environment variable
+		if env, ok := field.Tag.Lookup("environment"); ok {
+			if v, ok := os.LookupEnv(env); ok {
+				o := newOptions()
+				o.skipEnv = true
+
+				return setStructField(target, key, v, o)
+			}
+		}
+
+		return nil
+	})
+}
+
+// setDefault sets the default value of a field
+func setDefault(target interface{}, field reflect.StructField, key string, value string) error {
+	// default_separator lets the default be written using a different
+	// separator than the one the field splits on at runtime
+	if dsep, ok := field.Tag.Lookup("default_separator"); ok && dsep != "" {
+		parts := strings.Split(value, dsep)
+
+		sep := listSeparator(field)
+		if sep == "" {
+			// untagged slices take one item per set
+			o := newOptions()
+
+			for _, p := range parts {
+				err := setStructField(target, key, p, o)
+				if err != nil {
+					return err
+				}
+
+				o.appendList = true
+			}
+
+			return nil
+		}
+
+		value = strings.Join(parts, sep)
+	}
+
+	return SetStructFieldWithKey(target, key, value)
+}
+
+// SetFields sets every key in values on target like SetStructFieldWithKey honoring opts, keys
+// are processed in sorted order and the first error encountered is returned
+func SetFields(target interface{}, values map[string]string, opts ...Option) error {
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	o := newOptions(opts...)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key, err := o.key(k)
+		if err != nil {
+			return err
+		}
+
+		err = setStructField(target, key, values[k], o)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetStructFieldsFromMap sets every key in values on target, it stops at the first error and
+// keys not found on target fail with ErrUnknownKey. Use SetFieldsCollect to gather all errors
+func SetStructFieldsFromMap(target interface{}, values map[string]string) error {
+	return SetFields(target, values)
+}
+
+// SetFieldsCollect sets every key in values on target like SetStructFieldWithKey and
+// returns the errors encountered keyed by the key that failed, empty when all succeeded.
+//
+// This is a best-effort operation and not atomic, keys that could be set are set even
+// when others fail
+func SetFieldsCollect(target interface{}, values map[string]string, opts ...Option) map[string]error {
+	errs := make(map[string]error)
+
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		for k := range values {
+			errs[k] = errors.New("pointer is required")
+		}
+
+		return errs
+	}
+
+	o := newOptions(opts...)
+
+	for k, v := range values {
+		key, err := o.key(k)
+		if err != nil {
+			errs[k] = err
+			continue
+		}
+
+		err = setStructField(target, key, v, o)
+		if err != nil {
+			errs[k] = err
+		}
+	}
+
+	return errs
+}
+
+// Keys retrieves all the confkeys found on target in the order they are declared,
+// keys of nested structs are returned in their dotted form like tls.ca
+func Keys(target interface{}) ([]string, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+
+	err = walkFields(val, "", func(key string, _ reflect.StructField, _ reflect.Value) error {
+		if key != "" {
+			keys = append(keys, key)
+		}
+
+		return nil
+	})
+
+	return keys, err
+}
+
+// KeyForField retrieves the confkey of the struct field named field, the inverse of looking up a field by confkey
+func KeyForField(target interface{}, field string) (string, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return "", err
+	}
+
+	sf, ok := val.Type().FieldByName(field)
+	if !ok {
+		return "", fmt.Errorf("can't find any structure element named '%s'", field)
+	}
+
+	key, ok := confkeyTag(sf)
+	if !ok || key == "" {
+		return "", fmt.Errorf("structure element '%s' has no confkey", field)
+	}
+
+	return key, nil
+}
+
+// Merge overlays override onto base, both being the same struct type. Every confkey
+// field set to a non zero value in override replaces the value in base, fields tagged
+// mergeable:"always" are copied even when zero. Slices and maps are replaced, not combined
+func Merge(base interface{}, override interface{}) error {
+	if reflect.TypeOf(base).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	bv := reflect.ValueOf(base).Elem()
+	ov := reflect.ValueOf(override)
+	if ov.Kind() == reflect.Ptr {
+		ov = ov.Elem()
+	}
+
+	if bv.Type() != ov.Type() {
+		return fmt.Errorf("cannot merge %s into %s", ov.Type(), bv.Type())
+	}
+
+	overrides := make(map[string]reflect.Value)
+
+	err := walkFields(ov, "", func(key string, _ reflect.StructField, v reflect.Value) error {
+		overrides[key] = v
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return walkFields(bv, "", func(key string, field reflect.StructField, v reflect.Value) error {
+		o := overrides[key]
+
+		if !isZero(o) || field.Tag.Get("mergeable") == "always" {
+			v.Set(o)
+		}
+
+		return nil
+	})
+}
+
+// Clone creates a deep copy of target with slices, maps and pointers duplicated so the copy can
+// be changed without affecting target, a pointer is returned when target is a pointer
+func Clone(target interface{}) (interface{}, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := cloneValue(val)
+
+	if reflect.TypeOf(target).Kind() == reflect.Ptr {
+		ptr := reflect.New(clone.Type())
+		ptr.Elem().Set(clone)
+
+		return ptr.Interface(), nil
+	}
+
+	return clone.Interface(), nil
+}
+
+// cloneValue deep copies v, unexported struct fields are copied as is
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+
+		return c
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, k := range v.MapKeys() {
+			c.SetMapIndex(k, cloneValue(v.MapIndex(k)))
+		}
+
+		return c
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(cloneValue(v.Elem()))
+
+		return c
+
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(cloneValue(v.Field(i)))
+			}
+		}
+
+		return c
+	}
+
+	return v
+}
+
+// ReloadInto copies every confkey tagged field from a freshly loaded src into dst,
+// fields without a confkey or tagged confkey:"-" are left untouched in dst
+func ReloadInto(dst interface{}, src interface{}) error {
+	if reflect.TypeOf(dst).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src)
+	if sv.Kind() == reflect.Ptr {
+		sv = sv.Elem()
+	}
+
+	if dv.Type() != sv.Type() {
+		return fmt.Errorf("cannot reload %s from %s", dv.Type(), sv.Type())
+	}
+
+	for _, field := range structFields(dv.Type()) {
+		if _, ok := confkeyTag(field); ok {
+			dv.FieldByIndex(field.Index).Set(sv.FieldByIndex(field.Index))
+		}
+	}
+
+	return nil
+}
+
+// KindForKey retrieves the kind of the field on target that matches key, pointer fields
+// report the kind they point to
+func KindForKey(target interface{}, key string) (reflect.Kind, error) {
+	if !isStructPointer(target) {
+		return reflect.Invalid, errors.New("pointer is required")
+	}
+
+	_, kind, err := getFieldValAndKind(target, key)
+
+	return kind, err
+}
+
+// Get retrieves the value of the field on target that matches key as a T, an
+// error is returned when the key is not found or the field is not a T
+func Get[T any](target interface{}, key string) (T, error) {
+	var empty T
+
+	if !isStructPointer(target) {
+		return empty, errors.New("pointer is required")
+	}
+
+	field, _, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return empty, err
+	}
+
+	v, ok := field.Interface().(T)
+	if !ok {
+		return empty, fmt.Errorf("%s is %s, not %T", key, field.Type(), empty)
+	}
+
+	return v, nil
+}
+
+// StringFieldWithKey retrieves a string from target that matches key, "" when not found
+func StringFieldWithKey(target interface{}, key string) string {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return ""
+	}
+
+	if kind == reflect.String {
+		return field.String()
+	}
+
+	return ""
+}
+
+// StringListWithKey retrieves a []string from target that matches key, empty when not found
+func StringListWithKey(target interface{}, key string) []string {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return []string{}
+	}
+
+	if kind == reflect.Slice {
+		ptr, ok := field.Addr().Interface().(*[]string)
+		if !ok || *ptr == nil {
+			return []string{}
+		}
+
+		return []string(*ptr)
+	}
+
+	return []string{}
+}
+
+// BoolWithKey retrieves a bool from target that matches key, false when not found
+func BoolWithKey(target interface{}, key string) bool {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return false
+	}
+
+	if kind == reflect.Bool {
+		return field.Bool()
+	}
+
+	return false
+}
+
+// BoolFromIntWithKey retrieves an int or int64 from target that matches key as a bool, true when nonzero and false when not found
+func BoolFromIntWithKey(target interface{}, key string) bool {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return false
+	}
+
+	switch kind {
+	case reflect.Int, reflect.Int64:
+		return field.Int() != 0
+	}
+
+	return false
+}
+
+// IntWithKey retrieves an int from target that matches key, 0 when not found
+func IntWithKey(target interface{}, key string) int {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0
+	}
+
+	if kind == reflect.Int {
+		return int(field.Int())
+	}
+
+	return 0
+}
+
+// Int64WithKey retrieves an int from target that matches key, 0 when not found
+func Int64WithKey(target interface{}, key string) int64 {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0
+	}
+
+	if kind == reflect.Int64 {
+		return field.Int()
+	}
+
+	return 0
+}
+
+// DurationWithKey retrieves a time.Duration from target that matches key, 0 when not found
+func DurationWithKey(target interface{}, key string) time.Duration {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0
+	}
+
+	if kind == reflect.Int64 {
+		return time.Duration(field.Int())
+	}
+
+	return 0
+}
+
+// UintWithKey retrieves an uint from target that matches key, 0 when not found
+func UintWithKey(target interface{}, key string) uint {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0
+	}
+
+	if kind == reflect.Uint {
+		return uint(field.Uint())
+	}
+
+	return 0
+}
+
+// Uint64WithKey retrieves an uint64 from target that matches key, 0 when not found
+func Uint64WithKey(target interface{}, key string) uint64 {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0
+	}
+
+	if kind == reflect.Uint64 {
+		return field.Uint()
+	}
+
+	return 0
+}
+
+// FloatWithKey retrieves a float64 or float32 from target that matches key, 0 when not found
+func FloatWithKey(target interface{}, key string) float64 {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0
+	}
+
+	if kind == reflect.Float64 || kind == reflect.Float32 {
+		return field.Float()
+	}
+
+	return 0
+}
+
+// NumberWithKey retrieves any int, uint or float field from target that matches key as a float64, false when not found or not numeric
+func NumberWithKey(target interface{}, key string) (float64, bool) {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return 0, false
+	}
+
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(field.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(field.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), true
+	}
+
+	return 0, false
+}
+
+// LookupString retrieves a string from target that matches key, false when the key
+// is not found or the field holds its zero value and has no default
+func LookupString(target interface{}, key string) (string, bool) {
+	field, ok := lookupField(target, key, reflect.String)
+	if !ok {
+		return "", false
+	}
+
+	return field.String(), true
+}
+
+// LookupStringList retrieves a []string from target that matches key, false when the key
+// is not found or the field holds its zero value and has no default
+func LookupStringList(target interface{}, key string) ([]string, bool) {
+	field, ok := lookupField(target, key, reflect.Slice)
+	if !ok {
+		return []string{}, false
+	}
+
+	list, ok := field.Interface().([]string)
+	if !ok {
+		return []string{}, false
+	}
+
+	return list, true
+}
+
+// LookupBool retrieves a bool from target that matches key, false when the key
+// is not found or the field holds its zero value and has no default
+func LookupBool(target interface{}, key string) (bool, bool) {
+	field, ok := lookupField(target, key, reflect.Bool)
+	if !ok {
+		return false, false
+	}
+
+	return field.Bool(), true
+}
+
+// LookupInt retrieves an int from target that matches key, false when the key
+// is not found or the field holds its zero value and has no default
+func LookupInt(target interface{}, key string) (int, bool) {
+	field, ok := lookupField(target, key, reflect.Int)
+	if !ok {
+		return 0, false
+	}
+
+	return int(field.Int()), true
+}
+
+// LookupInt64 retrieves an int64 from target that matches key, false when the key
+// is not found or the field holds its zero value and has no default
+func LookupInt64(target interface{}, key string) (int64, bool) {
+	field, ok := lookupField(target, key, reflect.Int64)
+	if !ok {
+		return 0, false
+	}
+
+	return field.Int(), true
+}
+
+// TimeWithKey retrieves a time.Time from target that matches key, the zero time when not found
+func TimeWithKey(target interface{}, key string) time.Time {
+	field, kind, err := getFieldValAndKind(target, key)
+	if err != nil {
+		return time.Time{}
+	}
+
+	if kind == reflect.Struct {
+		if t, ok := field.Interface().(time.Time); ok {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// StringFieldWithKeyOr retrieves a string from target that matches key, fallback when not found or not a string
+func StringFieldWithKeyOr(target interface{}, key string, fallback string) string {
+	field, ok := fieldOfKind(target, key, reflect.String)
+	if !ok {
+		return fallback
+	}
+
+	return field.String()
+}
+
+// StringListWithKeyOr retrieves a []string from target that matches key, fallback when not found or not a []string
+func StringListWithKeyOr(target interface{}, key string, fallback []string) []string {
+	field, ok := fieldOfKind(target, key, reflect.Slice)
+	if !ok {
+		return fallback
+	}
+
+	list, ok := field.Interface().([]string)
+	if !ok {
+		return fallback
+	}
+
+	return list
+}
+
+// BoolWithKeyOr retrieves a bool from target that matches key, fallback when not found or not a bool
+func BoolWithKeyOr(target interface{}, key string, fallback bool) bool {
+	field, ok := fieldOfKind(target, key, reflect.Bool)
+	if !ok {
+		return fallback
+	}
+
+	return field.Bool()
+}
+
+// IntWithKeyOr retrieves an int from target that matches key, fallback when not found or not an int
+func IntWithKeyOr(target interface{}, key string, fallback int) int {
+	field, ok := fieldOfKind(target, key, reflect.Int)
+	if !ok {
+		return fallback
+	}
+
+	return int(field.Int())
+}
+
+// Int64WithKeyOr retrieves an int64 from target that matches key, fallback when not found or not an int64
+func Int64WithKeyOr(target interface{}, key string, fallback int64) int64 {
+	field, ok := fieldOfKind(target, key, reflect.Int64)
+	if !ok {
+		return fallback
+	}
+
+	return field.Int()
+}
+
+// FloatWithKeyOr retrieves a float64 or float32 from target that matches key, fallback when not found or not a float
+func FloatWithKeyOr(target interface{}, key string, fallback float64) float64 {
+	field, ok := fieldOfKind(target, key, reflect.Float64)
+	if !ok {
+		field, ok = fieldOfKind(target, key, reflect.Float32)
+	}
+	if !ok {
+		return fallback
+	}
+
+	return field.Float()
+}
+
+// DurationWithKeyOr retrieves a time.Duration from target that matches key, fallback when not found or not an int64
+func DurationWithKeyOr(target interface{}, key string, fallback time.Duration) time.Duration {
+	field, ok := fieldOfKind(target, key, reflect.Int64)
+	if !ok {
+		return fallback
+	}
+
+	return time.Duration(field.Int())
+}
+
+// fieldOfKind finds the field for key when it is of kind
+func fieldOfKind(target interface{}, key string, kind reflect.Kind) (reflect.Value, bool) {
+	field, k, err := getFieldValAndKind(target, key)
+	if err != nil || k != kind {
+		return reflect.Value{}, false
+	}
+
+	return field, true
+}
+
+// lookupField finds the field for key when it is of kind and either set or has a default
+func lookupField(target interface{}, key string, kind reflect.Kind) (reflect.Value, bool) {
+	field, k, err := getFieldValAndKind(target, key)
+	if err != nil || k != kind {
+		return reflect.Value{}, false
+	}
+
+	if isZero(field) {
+		item, _ := fieldWithKey(target, key)
+		if _, ok := tag(target, item, "default"); !ok {
+			return reflect.Value{}, false
+		}
+	}
+
+	return field, true
+}
+
+// getFieldValAndKind finds the field on target tagged with key, target has to be a
+// pointer to a struct and a clear panic is raised when it is not as that is a
+// programming error rather than a configuration one
+func getFieldValAndKind(target interface{}, key string) (reflect.Value, reflect.Kind, error) {
+	mustStructPointer(target)
+
+	item, err := fieldWithKey(target, key)
+	if errors.Is(err, ErrUnknownKey) {
+		if sub, rest, ok := nestedTarget(target, key); ok {
+			field, kind, nerr := getFieldValAndKind(sub, rest)
+			if !errors.Is(nerr, ErrUnknownKey) {
+				return field, kind, nerr
+			}
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, reflect.Invalid, err
+	}
+
+	field := reflect.ValueOf(target).Elem().FieldByName(item)
+
+	// pointer fields are dereferenced, nil pointers give the zero value
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field = reflect.New(field.Type().Elem()).Elem()
+		} else {
+			field = field.Elem()
+		}
+	}
+
+	return field, field.Kind(), nil
+}
+
+// isStructPointer is true when target is a non nil pointer to a struct
+func isStructPointer(target interface{}) bool {
+	v := reflect.ValueOf(target)
+
+	return v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct
+}
+
+func mustStructPointer(target interface{}) {
+	if target == nil {
+		panic("confkey: a pointer to a struct is required, got nil")
+	}
+
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("confkey: a pointer to a struct is required, got %T", target))
+	}
+}
+
+// SetStructFieldWithKey finds the struct key that matches the confkey on target and assign the value to it
+// value is usually a string, slice fields also accept a []string that is assigned without splitting
+func SetStructFieldWithKey(target interface{}, key string, value interface{}) error {
+	return setStructField(target, key, value, newOptions())
+}
+
+// SetStructFieldWithKeyWithEnvPrefix behaves like SetStructFieldWithKey but fields without an environment
+// tag are also overridden by the environment variable made from prefix and the upper cased confkey
+func SetStructFieldWithKeyWithEnvPrefix(target interface{}, key string, value interface{}, prefix string) error {
+	return setStructField(target, key, value, newOptions(WithEnvPrefix(prefix)))
+}
+
+// SetStructFieldWithKeyCaseInsensitive behaves like SetStructFieldWithKey but when no confkey matches
+// key exactly it is compared to the confkeys ignoring case, so LogLevel would set loglevel
+func SetStructFieldWithKeyCaseInsensitive(target interface{}, key string, value interface{}) error {
+	o := newOptions()
+	o.foldCase = true
+
+	return setStructField(target, key, value, o)
+}
+
+// SetStructFieldWithKeyStrict behaves like SetStructFieldWithKey but fails when the type tag of
+// the field is not one understood for its kind, this turns typos like comma_spit into errors
+func SetStructFieldWithKeyStrict(target interface{}, key string, value interface{}) error {
+	return setStructField(target, key, value, newOptions(WithStrict()))
+}
+
+func setStructField(target interface{}, key string, value interface{}, o *options) error {
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	item, err := fieldWithKey(target, key)
+	if errors.Is(err, ErrUnknownKey) && o.foldCase {
+		item, err = fieldWithKeyFold(target, key)
+	}
+	if errors.Is(err, ErrUnknownKey) {
+		// dotted keys like tls.ca set the ca key of the struct in the tls field
+		if sub, rest, ok := nestedTarget(target, key); ok {
+			nerr := setStructField(sub, rest, value, o)
+			if !errors.Is(nerr, ErrUnknownKey) {
+				return nerr
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	sf, _ := reflect.TypeOf(target).Elem().FieldByName(item)
+
+	negate, _ := aliasFor(sf, key)
+	if negate && field.Kind() != reflect.Bool && field.Type() != reflect.TypeOf((*bool)(nil)) {
+		return fmt.Errorf("negated alias '%s' can only be used on bool fields", key)
+	}
+
+	// env_append on a slice adds the environment items to the value rather than replacing it
+	envAppend := false
+	envValue := ""
+
+	confkey, _ := confkeyTag(sf)
+
+	if env := o.envName(sf, confkey); env != "" && !o.skipEnv {
+		if v, ok := os.LookupEnv(env); ok {
+			if ea, _ := tag(target, item, "env_append"); field.Kind() == reflect.Slice && ea == "true" {
+				envAppend = true
+				envValue = v
+			} else {
+				value = v
+				// the environment holds the value of the field, not the negated alias
+				negate = false
+			}
+		}
+	}
+
+	// lists from decoders like YAML are assigned to slices without joining and splitting them again
+	list, isList, err := stringList(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for key '%s': %s", key, err)
+	}
+
+	var str string
+
+	if isList {
+		if field.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot set a list for key '%s', %s fields require a single value", key, field.Kind())
+		}
+
+		for i, v := range list {
+			list[i], err = preProcess(confkey, strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		// loosely typed decoders like YAML might give us bools or numbers, these
+		// are turned into strings and parsed as if they came from a text file
+		str, err = valueString(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for key '%s': %s", key, err)
+		}
+
+		str, err = preProcess(confkey, str)
+		if err != nil {
+			return err
+		}
+
+	}
+
+	// expand:"true" replaces ${VAR} and $VAR with their environment values, it is not a
+	// type so it can be combined with the split types of slices
+	if tag, ok := tag(target, item, "expand"); ok && tag == "true" {
+		str = os.ExpandEnv(str)
+
+		for i, v := range list {
+			list[i] = os.ExpandEnv(v)
+		}
+	}
+
+	if confkey != "" {
+		if parser, ok := fieldParser(reflect.TypeOf(target).Elem(), confkey); ok {
+			if isList {
+				return fmt.Errorf("cannot set a list for key '%s' that has a field parser", key)
+			}
+
+			err = setWithFieldParser(field, confkey, parser, str)
+			if err != nil {
+				return err
+			}
+
+			_, err = validator.ValidateStructField(target, item)
+
+			return err
+		}
+	}
+
+	if setter, ok := field.Addr().Interface().(Setter); ok {
+		if isList {
+			return fmt.Errorf("cannot set a list for key '%s' that implements Setter", key)
+		}
+
+		err = setter.SetConfkey(str)
+		if err != nil {
+			return err
+		}
+
+		_, err = validator.ValidateStructField(target, item)
+
+		return err
+	}
+
+	if conv, ok := converter(field.Type()); ok {
+		if isList {
+			return fmt.Errorf("cannot set a list for key '%s' that has a converter", key)
+		}
+
+		err = setWithFieldParser(field, key, conv, str)
+		if err != nil {
+			return err
+		}
+
+		_, err = validator.ValidateStructField(target, item)
+
+		return err
+	}
+
+	// pointer fields are parsed into a new value that is only assigned once it is
+	// valid, fields that are never set remain nil
+	var pointer reflect.Value
+	if field.Kind() == reflect.Ptr {
+		pointer = field
+		field = reflect.New(field.Type().Elem()).Elem()
+	}
+
+	if o.strict {
+		err = checkTypeTag(sf, field.Kind(), key)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Slice:
+		vals := list
+		if !isList {
+			vals, err = splitListValue(target, item, str)
+			if err != nil {
+				return fmt.Errorf("invalid value for key '%s': %s", key, err)
+			}
+		}
+
+		if envAppend {
+			evals, err := splitListValue(target, item, envValue)
+			if err != nil {
+				return fmt.Errorf("invalid environment value for key '%s': %s", key, err)
+			}
+
+			vals = append(vals, evals...)
+		}
+
+		if tag, _ := tag(target, item, "type"); tag == "filepath" {
+			for i, v := range vals {
+				vals[i], err = expandHome(v)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// setting a slice replaces it so applying defaults and then a file does not
+		// accumulate duplicates, repeated keys within a single file are appended
+		list := reflect.MakeSlice(field.Type(), 0, len(vals))
+		if o.appendList {
+			list = field
+		}
+
+		for _, v := range vals {
+			ev, err := parseSliceElement(field.Type().Elem(), v)
+			if err != nil {
+				return fmt.Errorf("invalid %s item '%s': %s", key, v, err)
+			}
+
+			list = reflect.Append(list, ev)
+		}
+
+		if tag, ok := tag(target, item, "len"); ok {
+			expected, err := strconv.Atoi(tag)
+			if err != nil {
+				return fmt.Errorf("invalid len '%s' for key '%s': %s", tag, key, err)
+			}
+
+			if list.Len() != expected {
+				return fmt.Errorf("%s requires %d items but %d were given", key, expected, list.Len())
+			}
+		}
+
+		if tag, ok := tag(target, item, "max_total_len"); ok {
+			max, err := strconv.Atoi(tag)
+			if err != nil {
+				return fmt.Errorf("invalid max_total_len '%s' for key '%s': %s", tag, key, err)
+			}
+
+			total := 0
+			for i := 0; i < list.Len(); i++ {
+				total += len(fmt.Sprint(list.Index(i).Interface()))
+			}
+
+			if total > max {
+				return fmt.Errorf("%s exceeds the maximum total length of %d with %d", key, max, total)
+			}
+		}
+
+		field.Set(list)
+
+	case reflect.Int:
+		v := strings.TrimSpace(str)
+		if suffix, ok := tag(target, item, "strip_suffix"); ok {
+			v = strings.TrimSuffix(v, suffix)
+		}
+
+		var i int
+		if tag, _ := tag(target, item, "type"); tag == "bytes" {
+			b, err := parseBytes(v)
+			if err != nil {
+				return err
+			}
+
+			if strconv.IntSize < 64 && b > 1<<(strconv.IntSize-1)-1 {
+				return fmt.Errorf("invalid size '%s', value out of range", v)
+			}
+			i = int(b)
+		} else {
+			// base 0 honors 0x, 0o and 0b prefixes for masks and bitsets
+			n, err := strconv.ParseInt(v, 0, strconv.IntSize)
+			if err != nil {
+				return err
+			}
+			i = int(n)
+		}
+
+		err = checkSign(target, item, key, float64(i))
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(int64(i))
+
+	case reflect.Int64:
+		tag, _ := tag(target, item, "type")
+		switch tag {
+		case "duration":
+			d, err := parseDuration(str)
+			if err != nil {
+				return err
+			}
+
+			err = checkSign(target, item, key, float64(d))
+			if err != nil {
+				return err
+			}
+
+			field.SetInt(int64(d))
+
+		case "bytes":
+			b, err := parseBytes(str)
+			if err != nil {
+				return err
+			}
+
+			err = checkSign(target, item, key, float64(b))
+			if err != nil {
+				return err
+			}
+
+			field.SetInt(b)
+
+		default:
+			i, err := strconv.ParseInt(strings.TrimSpace(str), 0, 64)
+			if err != nil {
+				return err
+			}
+
+			err = checkSign(target, item, key, float64(i))
+			if err != nil {
+				return err
+			}
+
+			field.SetInt(i)
+		}
+
+	case reflect.Uint, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(str), 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		err = checkSign(target, item, key, float64(u))
+		if err != nil {
+			return err
+		}
+
+		field.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(str), field.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		err = checkSign(target, item, key, f)
+		if err != nil {
+			return err
+		}
+
+		field.SetFloat(f)
+
+	case reflect.String:
+		v := str
+
+		if tag, ok := tag(target, item, "type"); ok {
+			switch tag {
+			case "title_string":
+				a := []rune(str)
+				if len(a) > 0 {
+					a[0] = unicode.ToUpper(a[0])
+				}
+				v = string(a)
+			case "upper_string":
+				v = strings.ToUpper(str)
+			case "lower_string":
+				v = strings.ToLower(str)
+			case "path_string":
+				a := strings.TrimSpace(str)
+				if a != "" && a[0] == '~' {
+					home, err := homeDir()
+					if err != nil {
+						return err
+					}
+					a = strings.Replace(a, "~", home, 1)
+				}
+				v = a
+			case "filepath":
+				a, err := expandHome(strings.TrimSpace(str))
+				if err != nil {
+					return err
+				}
+				v = a
+			case "url":
+				u, err := parseURL(key, str)
+				if err != nil {
+					return err
+				}
+				v = u.String()
+			}
+		}
+
+		if prefix, ok := tag(target, item, "trim_prefix"); ok {
+			v = strings.TrimPrefix(v, prefix)
+		}
+
+		if suffix, ok := tag(target, item, "trim_suffix"); ok {
+			v = strings.TrimSuffix(v, suffix)
+		}
+
+		if pattern, ok := tag(target, item, "match"); ok {
+			err := checkMatch(key, pattern, v)
+			if err != nil {
+				return err
+			}
+		}
+
+		field.SetString(v)
+
+	case reflect.Map:
+		if tag, _ := tag(target, item, "type"); tag != "kv_split" {
+			return unsupportedKind(item, key, field)
+		}
+
+		// maps are set from a single value like 'k1=v1,k2=v2'
+		m, ok := field.Addr().Interface().(*map[string]string)
+		if !ok {
+			return fmt.Errorf("kv_split is only supported on map[string]string fields, %s is %s", key, field.Type())
+		}
+
+		parsed := make(map[string]string)
+
+		for _, pair := range strings.Split(str, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid item '%s' for key '%s', expected key=value", strings.TrimSpace(pair), key)
+			}
+
+			parsed[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+
+		*m = parsed
+
+	case reflect.Struct:
+		switch tag, _ := tag(target, item, "type"); tag {
+		case "time":
+			ptr, ok := field.Addr().Interface().(*time.Time)
+			if !ok {
+				return fmt.Errorf("type time is only supported on time.Time fields, %s is %s", key, field.Type())
+			}
+
+			t, err := time.Parse(timeLayout(sf), strings.TrimSpace(str))
+			if err != nil {
+				return err
+			}
+
+			*ptr = t
+
+		case "url":
+			ptr, ok := field.Addr().Interface().(*url.URL)
+			if !ok {
+				return fmt.Errorf("type url is only supported on string and url.URL fields, %s is %s", key, field.Type())
+			}
+
+			u, err := parseURL(key, str)
+			if err != nil {
+				return err
+			}
+
+			*ptr = *u
+
+		case "inline":
+			// inline structs are set from a single value like 'retries=3,timeout=5s'
+			for _, pair := range strings.Split(str, ",") {
+				if strings.TrimSpace(pair) == "" {
+					continue
+				}
+
+				kv := strings.SplitN(pair, "=", 2)
+				if len(kv) != 2 {
+					return fmt.Errorf("invalid inline setting '%s' for key '%s', expected key=value", strings.TrimSpace(pair), key)
+				}
+
+				err := SetStructFieldWithKey(field.Addr().Interface(), strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+				if err != nil {
+					return err
+				}
+			}
+
+		default:
+			return unsupportedKind(item, key, field)
+		}
+
+	case reflect.Bool:
+		b, err := strToBool(str)
+
+		// bool_numeric:"nonzero" treats any integer other than 0 as true
+		if tag, ok := tag(target, item, "bool_numeric"); ok && tag == "nonzero" {
+			if i, ierr := strconv.Atoi(strings.TrimSpace(str)); ierr == nil {
+				b = i != 0
+				err = nil
+			}
+		}
+
+		// absent_false:"true" makes a key given without a value true, like a flag
+		if tag, ok := tag(target, item, "absent_false"); ok && tag == "true" && strings.TrimSpace(str) == "" {
+			b = true
+			err = nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if negate {
+			b = !b
+		}
+
+		field.SetBool(b)
+
+	default:
+		return unsupportedKind(item, key, field)
+	}
+
+	if pointer.IsValid() {
+		pointer.Set(field.Addr())
+	}
+
+	_, err = validator.ValidateStructField(target, item)
 
 	return err
 }
 
-// SetStructDefaults extract defaults out of the tags and set them to the key
-func SetStructDefaults(target interface{}) error {
-	if reflect.TypeOf(target).Kind() != reflect.Ptr {
-		return errors.New("pointer is required")
+// unsupportedKind is the error returned when field cannot be set from a string
+func unsupportedKind(item string, key string, field reflect.Value) error {
+	return fmt.Errorf("cannot set %s for key '%s', %s fields are not supported", item, key, field.Type())
+}
+
+// checkTypeTag ensures the type tag of field, if any, is one understood for kind
+func checkTypeTag(field reflect.StructField, kind reflect.Kind, key string) error {
+	t, ok := field.Tag.Lookup("type")
+	if !ok {
+		return nil
 	}
 
-	st := reflect.TypeOf(target).Elem()
+	for _, known := range typeTags[kind] {
+		if t == known {
+			return nil
+		}
+	}
 
-	for i := 0; i <= st.NumField()-1; i++ {
-		field := st.Field(i)
+	return fmt.Errorf("unknown type '%s' for key '%s' of kind %s", t, key, kind)
+}
 
-		if key, ok := field.Tag.Lookup("confkey"); ok {
-			if value, ok := field.Tag.Lookup("default"); ok {
-				err := SetStructFieldWithKey(target, key, value)
-				if err != nil {
-					return err
-				}
-			}
+// parseSliceElement converts a single item of a list into the element type of the slice
+func parseSliceElement(t reflect.Type, value string) (reflect.Value, error) {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		d, err := parseDuration(value)
+		if err != nil {
+			return reflect.Value{}, err
 		}
+
+		return reflect.ValueOf(d), nil
 	}
 
-	return nil
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(value).Convert(t), nil
+
+	case reflect.Int:
+		i, err := strconv.ParseInt(value, 0, strconv.IntSize)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		return reflect.ValueOf(int(i)).Convert(t), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("unsupported slice element type %s", t)
 }
 
-// StringFieldWithKey retrieves a string from target that matches key, "" when not found
-func StringFieldWithKey(target interface{}, key string) string {
-	item, err := fieldWithKey(target, key)
+// parseURL parses value as a URL for key
+func parseURL(key string, value string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(value))
 	if err != nil {
-		return ""
+		return nil, fmt.Errorf("invalid url for key '%s': %s", key, err)
 	}
 
-	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	return u, nil
+}
 
-	if field.Kind() == reflect.String {
-		ptr := field.Addr().Interface().(*string)
+// isLeafStruct is true for struct types set from a single value rather than holding nested keys
+func isLeafStruct(t reflect.Type) bool {
+	return t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(url.URL{})
+}
 
-		return string(*ptr)
+// timeLayout is the layout set using the format tag, time.RFC3339 when not set
+func timeLayout(field reflect.StructField) string {
+	if format, ok := field.Tag.Lookup("format"); ok && format != "" {
+		return format
 	}
 
-	return ""
+	return time.RFC3339
 }
 
-// StringListWithKey retrieves a []string from target that matches key, empty when not found
-func StringListWithKey(target interface{}, key string) []string {
-	item, err := fieldWithKey(target, key)
+// parseDuration parses a duration, plain integers are taken to be seconds and
+// integers followed by d or w are taken to be days or weeks, all may be negative
+func parseDuration(value string) (time.Duration, error) {
+	intonly, err := regexp.MatchString("\\A-?\\d+\\z", value)
 	if err != nil {
-		return []string{}
+		return 0, err
 	}
 
-	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	if intonly {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, err
+		}
 
-	if field.Kind() == reflect.Slice {
-		ptr := field.Addr().Interface().(*[]string)
+		return time.Second * time.Duration(i), nil
+	}
 
-		if *ptr == nil {
-			return []string{}
+	parts := regexp.MustCompile(`\A(-?\d+)([dw])\z`).FindStringSubmatch(value)
+	if len(parts) == 3 {
+		i, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, err
 		}
 
-		return []string(*ptr)
+		if parts[2] == "w" {
+			return 7 * 24 * time.Hour * time.Duration(i), nil
+		}
+
+		return 24 * time.Hour * time.Duration(i), nil
 	}
 
-	return []string{}
+	return time.ParseDuration(value)
 }
 
-// BoolWithKey retrieves a bool from target that matches key, false when not found
-func BoolWithKey(target interface{}, key string) bool {
-	item, err := fieldWithKey(target, key)
+// byteUnits are the multipliers of the size suffixes parseBytes understands, keyed by their upper case form
+var byteUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"KIB": 1024,
+	"MIB": 1024 * 1024,
+	"GIB": 1024 * 1024 * 1024,
+	"TIB": 1024 * 1024 * 1024 * 1024,
+}
+
+// parseBytes parses a size like 10MB or 2GiB into bytes, plain integers are taken to be bytes
+func parseBytes(value string) (int64, error) {
+	parts := regexp.MustCompile(`\A\s*(\d+(?:\.\d+)?)\s*([a-zA-Z]*)\s*\z`).FindStringSubmatch(value)
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+
+	unit, ok := byteUnits[strings.ToUpper(parts[2])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size '%s', unknown suffix '%s'", value, parts[2])
+	}
+
+	f, err := strconv.ParseFloat(parts[1], 64)
 	if err != nil {
-		return false
+		return 0, err
 	}
 
-	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	// float64(math.MaxInt64) rounds up to 2^63 which does not fit an int64 either
+	b := f * unit
+	if b >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size '%s', value out of range", value)
+	}
 
-	if field.Kind() == reflect.Bool {
-		ptr := field.Addr().Interface().(*bool)
+	return int64(b), nil
+}
 
-		return bool(*ptr)
+// checkSign enforces the positive and non_negative tags on numeric fields
+func checkSign(target interface{}, item string, key string, v float64) error {
+	if tag, ok := tag(target, item, "positive"); ok && tag == "true" && v <= 0 {
+		return fmt.Errorf("%s must be greater than 0", key)
 	}
 
-	return false
+	if tag, ok := tag(target, item, "non_negative"); ok && tag == "true" && v < 0 {
+		return fmt.Errorf("%s may not be negative", key)
+	}
+
+	return nil
 }
 
-// IntWithKey retrieves an int from target that matches key, 0 when not found
-func IntWithKey(target interface{}, key string) int {
-	item, err := fieldWithKey(target, key)
-	if err != nil {
-		return 0
+// scalarString converts strings, bools and numbers to their string form
+func scalarString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
 	}
 
-	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	return "", false
+}
 
-	if field.Kind() == reflect.Int {
-		ptr := field.Addr().Interface().(*int)
+// valueString converts scalars, including named types like time.Duration, to their string form
+func valueString(value interface{}) (string, error) {
+	if s, ok := scalarString(value); ok {
+		return s, nil
+	}
 
-		return int(*ptr)
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return "", errors.New("a value is required")
 	}
 
-	return 0
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value), nil
+	}
+
+	return "", fmt.Errorf("unsupported value of type %T", value)
 }
 
-// Int64WithKey retrieves an int from target that matches key, 0 when not found
-func Int64WithKey(target interface{}, key string) int64 {
-	item, err := fieldWithKey(target, key)
-	if err != nil {
-		return 0
+// stringList converts []string and []interface{} holding only scalars to a new []string
+func stringList(value interface{}) ([]string, bool, error) {
+	switch v := value.(type) {
+	case []string:
+		return append([]string{}, v...), true, nil
+
+	case []interface{}:
+		list := []string{}
+		for _, i := range v {
+			s, ok := scalarString(i)
+			if !ok {
+				return nil, true, fmt.Errorf("unsupported list item %v", i)
+			}
+
+			list = append(list, s)
+		}
+
+		return list, true, nil
 	}
 
-	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	return nil, false, nil
+}
+
+// listSeparator is the separator used to split a slice field, the separator tag
+// takes precedence over the one implied by the type tag, "" when not known
+func listSeparator(field reflect.StructField) string {
+	if sep, ok := field.Tag.Lookup("separator"); ok && sep != "" {
+		return sep
+	}
+
+	switch field.Tag.Get("type") {
+	case "comma_split":
+		return ","
 
-	if field.Kind() == reflect.Int64 {
-		ptr := field.Addr().Interface().(*int64)
+	case "semicolon_split":
+		// lists exported by windows tools often use ; between items
+		return ";"
 
-		return int64(*ptr)
+	case "colon_split":
+		// these are like libdir, but we want to always use : to split and not
+		// os path like path_split would do
+		return ":"
+
+	case "path_split":
+		// these are like libdir, either a one line split or a multiple occurance with splits
+		return string(os.PathListSeparator)
+
+	case "line_split":
+		// multi line blocks with an item per line, \r is removed when trimming
+		return "\n"
 	}
 
-	return 0
+	return ""
 }
 
-// SetStructFieldWithKey finds the struct key that matches the confkey on target and assign the value to it
-func SetStructFieldWithKey(target interface{}, key string, value interface{}) error {
-	if reflect.TypeOf(target).Kind() != reflect.Ptr {
-		return errors.New("pointer is required")
+// splitListValue splits value into the items to store in a slice field based on its type or
+// separator tag, when tagged split_escapes:"true" a separator preceded by a backslash is kept in the item
+func splitListValue(target interface{}, item string, value string) ([]string, error) {
+	field, _ := reflect.TypeOf(target).Elem().FieldByName(item)
+
+	t, ok := field.Tag.Lookup("type")
+
+	// filepath lists are not split unless a separator is set
+	if _, custom := field.Tag.Lookup("separator"); (!ok || t == "filepath") && !custom {
+		return []string{strings.TrimSpace(value)}, nil
 	}
 
-	item, err := fieldWithKey(target, key)
-	if err != nil {
-		return err
+	sep := listSeparator(field)
+	if sep == "" || strings.TrimSpace(value) == "" {
+		return []string{}, nil
+	}
+
+	parts := strings.Split(value, sep)
+
+	if escapes, ok := tag(target, item, "split_escapes"); ok && escapes == "true" {
+		var err error
+
+		parts, err = splitEscaped(value, sep)
+		if err != nil {
+			return nil, err
+		}
+	} else if t == "comma_split" {
+		// quoted items are returned as is without trimming
+		return splitQuoted(value, sep)
+	}
+
+	vals := []string{}
+	for _, v := range parts {
+		vals = append(vals, strings.TrimSpace(v))
 	}
 
-	if tag, ok := tag(target, item, "environment"); ok {
-		if v, ok := os.LookupEnv(tag); ok {
-			value = v
+	if t == "semicolon_split" {
+		// a trailing ; does not start a new item
+		for len(vals) > 0 && vals[len(vals)-1] == "" {
+			vals = vals[:len(vals)-1]
 		}
 	}
 
-	field := reflect.ValueOf(target).Elem().FieldByName(item)
+	if t == "line_split" {
+		lines := []string{}
+		for _, v := range vals {
+			if v != "" {
+				lines = append(lines, v)
+			}
+		}
+		vals = lines
+	}
 
-	switch field.Kind() {
-	case reflect.Slice:
-		ptr := field.Addr().Interface().(*[]string)
+	return vals, nil
+}
 
-		if tag, ok := tag(target, item, "type"); ok {
-			switch tag {
-			case "comma_split":
-				// specifically clear it since these are one line split like 'collectives'
-				*ptr = []string{}
-				vals := strings.Split(value.(string), ",")
+// splitEscaped splits value on sep while honoring backslash escapes, \\ is a literal backslash
+func splitEscaped(value string, sep string) ([]string, error) {
+	parts := []string{}
+	current := strings.Builder{}
 
-				for _, v := range vals {
-					*ptr = append(*ptr, strings.TrimSpace(v))
-				}
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\':
+			if i == len(value)-1 {
+				return nil, errors.New("trailing backslash")
+			}
 
-			case "colon_split":
-				// these are like libdir, but we want to always use : to split and not
-				// os path like path_split would do
-				vals := strings.Split(value.(string), ":")
+			i++
+			current.WriteByte(value[i])
 
-				for _, v := range vals {
-					*ptr = append(*ptr, strings.TrimSpace(v))
-				}
+		case strings.HasPrefix(value[i:], sep):
+			parts = append(parts, current.String())
+			current.Reset()
+			i += len(sep) - 1
 
-			case "path_split":
-				// these are like libdir, either a one line split or a multiple occurance with splits
-				vals := strings.Split(value.(string), string(os.PathListSeparator))
+		default:
+			current.WriteByte(value[i])
+		}
+	}
 
-				for _, v := range vals {
-					*ptr = append(*ptr, strings.TrimSpace(v))
-				}
+	return append(parts, current.String()), nil
+}
+
+// splitQuoted splits value on sep CSV style, items wrapped in double quotes keep any
+// separators they contain and "" within them is a literal quote, other items are trimmed
+func splitQuoted(value string, sep string) ([]string, error) {
+	parts := []string{}
+	rest := value
+
+	for {
+		trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+
+		if !strings.HasPrefix(trimmed, `"`) {
+			i := strings.Index(rest, sep)
+			if i == -1 {
+				return append(parts, strings.TrimSpace(rest)), nil
 			}
-		} else {
-			*ptr = append(*ptr, strings.TrimSpace(value.(string)))
-		}
 
-	case reflect.Int:
-		ptr := field.Addr().Interface().(*int)
-		i, err := strconv.Atoi(value.(string))
-		if err != nil {
-			return err
+			parts = append(parts, strings.TrimSpace(rest[:i]))
+			rest = rest[i+len(sep):]
+
+			continue
 		}
-		*ptr = i
 
-	case reflect.Int64:
-		if tag, ok := tag(target, item, "type"); ok {
-			if tag == "duration" {
-				ptr := field.Addr().Interface().(*time.Duration)
+		current := strings.Builder{}
+		closed := false
+		i := 1
 
-				intonly, err := regexp.MatchString("\\A\\d+\\z", value.(string))
-				if err != nil {
-					return err
+		for ; i < len(trimmed); i++ {
+			if trimmed[i] == '"' {
+				if i+1 < len(trimmed) && trimmed[i+1] == '"' {
+					current.WriteByte('"')
+					i++
+					continue
 				}
 
-				if intonly {
-					i, err := strconv.Atoi(value.(string))
-					if err != nil {
-						return err
-					}
+				closed = true
+				i++
+				break
+			}
 
-					*ptr = time.Second * time.Duration(i)
+			current.WriteByte(trimmed[i])
+		}
 
-					break
-				}
+		if !closed {
+			return nil, fmt.Errorf("unterminated quote in %s", trimmed)
+		}
 
-				d, err := time.ParseDuration(value.(string))
-				if err != nil {
-					return err
-				}
+		parts = append(parts, current.String())
 
-				*ptr = d
-			}
+		rest = strings.TrimLeftFunc(trimmed[i:], unicode.IsSpace)
+		if rest == "" {
+			return parts, nil
 		}
 
-	case reflect.String:
-		ptr := field.Addr().Interface().(*string)
-		*ptr = value.(string)
+		if !strings.HasPrefix(rest, sep) {
+			return nil, fmt.Errorf("unexpected text after quoted item \"%s\"", current.String())
+		}
 
-		if tag, ok := tag(target, item, "type"); ok {
-			switch tag {
-			case "title_string":
-				a := []rune(value.(string))
-				a[0] = unicode.ToUpper(a[0])
-				*ptr = string(a)
-			case "path_string":
-				a := strings.TrimSpace(value.(string))
-				if a[0] == '~' {
-					home, err := homeDir()
-					if err != nil {
-						return err
-					}
-					a = strings.Replace(a, "~", home, 1)
-				}
-				*ptr = a
-			}
+		rest = rest[len(sep):]
+	}
+}
+
+// expandHome expands a leading ~ or ~user in path to the home directory of
+// the current or named user, other paths are returned unchanged
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+
+	name := path[1:]
+	rest := ""
+	if i := strings.IndexAny(name, `/\`); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+
+	if name == "" {
+		home, err := homeDir()
+		if err != nil {
+			return "", err
 		}
 
-	case reflect.Bool:
-		ptr := field.Addr().Interface().(*bool)
-		b, _ := strToBool(value.(string))
-		*ptr = b
+		return home + rest, nil
 	}
 
-	_, err = validator.ValidateStructField(target, item)
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("cannot expand %s: %s", path, err)
+	}
 
-	return err
+	return u.HomeDir + rest, nil
 }
 
 func homeDir() (string, error) {
@@ -317,6 +2215,69 @@ func homeDir() (string, error) {
 	return home, nil
 }
 
+// confkeyTag retrieves the confkey of a field, fields tagged confkey:"-" are excluded
+func confkeyTag(field reflect.StructField) (string, bool) {
+	key, ok := field.Tag.Lookup("confkey")
+	if !ok || key == "-" {
+		return "", false
+	}
+
+	return key, true
+}
+
+// nestedTarget finds the nested struct field of target that a dotted key refers to, it returns
+// a pointer to the nested struct and the remainder of the key to look up within it
+func nestedTarget(target interface{}, key string) (interface{}, string, bool) {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '.' {
+			continue
+		}
+
+		item, err := fieldWithKey(target, key[:i])
+		if err != nil {
+			continue
+		}
+
+		sf, _ := reflect.TypeOf(target).Elem().FieldByName(item)
+		field := reflect.ValueOf(target).Elem().FieldByName(item)
+
+		if field.Kind() == reflect.Struct && !isLeafStruct(field.Type()) && sf.Tag.Get("type") != "inline" {
+			return field.Addr().Interface(), key[i+1:], true
+		}
+	}
+
+	return nil, "", false
+}
+
+// structFields retrieves the fields of st including those promoted from embedded structs
+// without a confkey, promoted fields are only included when they can be reached by name so
+// ones shadowed by the outer struct or ambiguous between embedded structs are skipped
+func structFields(st reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	embedded := []reflect.StructField{}
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+		fields = append(fields, field)
+
+		if _, ok := confkeyTag(field); !ok && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field)
+		}
+	}
+
+	for _, e := range embedded {
+		for _, field := range structFields(e.Type) {
+			field.Index = append([]int{e.Index[0]}, field.Index...)
+
+			if promoted, ok := st.FieldByName(field.Name); ok && reflect.DeepEqual(promoted.Index, field.Index) {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
 // determines the struct key name that is tagged with a certain confkey
 func fieldWithKey(s interface{}, key string) (string, error) {
 	st := reflect.TypeOf(s)
@@ -324,17 +2285,58 @@ func fieldWithKey(s interface{}, key string) (string, error) {
 		st = st.Elem()
 	}
 
-	for i := 0; i <= st.NumField()-1; i++ {
-		field := st.Field(i)
+	ti := cachedType(st)
+
+	if name, ok := ti.keys[key]; ok {
+		return name, nil
+	}
+
+	if name, ok := ti.aliases[key]; ok {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("%w '%s'", ErrUnknownKey, key)
+}
+
+// fieldWithKeyFold determines the struct key name with a confkey that matches key ignoring case
+func fieldWithKeyFold(s interface{}, key string) (string, error) {
+	st := reflect.TypeOf(s)
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
 
-		if confkey, ok := field.Tag.Lookup("confkey"); ok {
-			if confkey == key {
+	for _, field := range cachedType(st).fields {
+		if confkey, ok := confkeyTag(field); ok {
+			if strings.EqualFold(confkey, key) {
 				return field.Name, nil
 			}
 		}
 	}
 
-	return "", fmt.Errorf("can't find any structure element configured with confkey '%s'", key)
+	return "", fmt.Errorf("%w '%s'", ErrUnknownKey, key)
+}
+
+// aliasFor checks if key is one of the aliases of field, a negated alias
+// is written with a leading ! like aliases:"!no_feature"
+func aliasFor(field reflect.StructField, key string) (negated bool, found bool) {
+	aliases, ok := field.Tag.Lookup("aliases")
+	if !ok {
+		return false, false
+	}
+
+	for _, alias := range strings.Split(aliases, ",") {
+		alias = strings.TrimSpace(alias)
+
+		if alias == key {
+			return false, true
+		}
+
+		if strings.HasPrefix(alias, "!") && alias[1:] == key {
+			return true, true
+		}
+	}
+
+	return false, false
 }
 
 // retrieve a tag for a struct field
@@ -345,13 +2347,9 @@ func tag(s interface{}, field string, tag string) (string, bool) {
 		st = st.Elem()
 	}
 
-	for i := 0; i <= st.NumField()-1; i++ {
-		f := st.Field(i)
-
-		if f.Name == field {
-			if value, ok := f.Tag.Lookup(tag); ok {
-				return value, true
-			}
+	if t, ok := cachedType(st).tags[field]; ok {
+		if value, ok := t.Lookup(tag); ok {
+			return value, true
 		}
 	}
 
@@ -362,6 +2360,7 @@ func tag(s interface{}, field string, tag string) (string, bool) {
 //
 // 1, yes, true, y, t will be true
 // 0, no, false, n, f will be false
+// words registered using RegisterBoolWords are also accepted
 // anything else will be false with an error
 func strToBool(s string) (bool, error) {
 	clean := strings.TrimSpace(s)
@@ -374,5 +2373,9 @@ func strToBool(s string) (bool, error) {
 		return false, nil
 	}
 
+	if b, ok := boolWord(clean); ok {
+		return b, nil
+	}
+
 	return false, errors.New("cannot convert string value '" + clean + "' into a boolean.")
 }
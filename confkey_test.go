@@ -1,8 +1,17 @@
 package confkey
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -16,20 +25,297 @@ func TestFileContent(t *testing.T) {
 	RunSpecs(t, "Confkey")
 }
 
+func panicMessage(f func()) (msg interface{}) {
+	defer func() {
+		msg = recover()
+	}()
+
+	f()
+
+	return nil
+}
+
 type TestData struct {
 	PlainString string        `confkey:"plain_string" validate:"shellsafe"`
 	CommaSplit  []string      `confkey:"comma_split" type:"comma_split"`
 	PathSplit   []string      `confkey:"path_split" type:"path_split"`
 	ColonSplit  []string      `confkey:"colon_split" type:"colon_split"`
+	SemiSplit   []string      `confkey:"semicolon_split" type:"semicolon_split"`
+	LineSplit   []string      `confkey:"line_split" type:"line_split"`
+	Untagged    []string      `confkey:"untagged"`
 	StringEnum  string        `confkey:"loglevel" validate:"enum=debug,info,warn" default:"warn"`
 	Int         int           `confkey:"int"`
 	Int64       int64         `confkey:"int64"`
 	TitleString string        `confkey:"title_string" type:"title_string"`
 	PathString  string        `confkey:"path_string" type:"path_string"`
+	UpperString string        `confkey:"upper_string" type:"upper_string"`
+	LowerString string        `confkey:"lower_string" type:"lower_string"`
 	Bool        bool          `confkey:"bool"`
+	Bounded     []string      `confkey:"bounded" type:"comma_split" max_total_len:"10"`
+	Escaped     []string      `confkey:"escaped" type:"comma_split" split_escapes:"true"`
+	Range       []string      `confkey:"range" type:"comma_split" len:"2"`
+	Count       int           `confkey:"count" positive:"true"`
+	Seconds     int           `confkey:"seconds" strip_suffix:"s"`
+	BaseURL     string        `confkey:"base_url" trim_suffix:"/"`
+	Host        string        `confkey:"host" trim_prefix:"https://" trim_suffix:"/"`
+	Offset      int           `confkey:"offset" non_negative:"true"`
+	Timeout     time.Duration `confkey:"timeout" type:"duration" positive:"true"`
+	Ratio       float64       `confkey:"ratio" default:"0.75" environment:"TEST_RATIO"`
+	Ratio32     float32       `confkey:"ratio32"`
+	Uint        uint          `confkey:"uint"`
+	Uint64      uint64        `confkey:"uint64"`
+	NumericBool bool          `confkey:"numeric_bool" bool_numeric:"nonzero"`
+	Flag        bool          `confkey:"flag" absent_false:"true"`
 	T           time.Duration `confkey:"interval" type:"duration" default:"1h"`
 }
 
+type TimeData struct {
+	Start time.Time `confkey:"start" type:"time"`
+	Day   time.Time `confkey:"day" type:"time" format:"2006-01-02"`
+}
+
+type IntListData struct {
+	Ports []int `confkey:"ports" type:"comma_split"`
+}
+
+type ExpandData struct {
+	Dir     string   `confkey:"dir" expand:"true" default:"${EXPAND_HOME}/.config/app"`
+	Literal string   `confkey:"literal"`
+	Paths   []string `confkey:"paths" type:"comma_split" expand:"true" default:"$EXPAND_HOME/a,/b"`
+	Dirs    []string `confkey:"dirs" expand:"true"`
+}
+
+type CaseData struct {
+	Loglevel string `confkey:"loglevel"`
+	Upper    string `confkey:"LogLevel"`
+}
+
+type PointerData struct {
+	Port     *int           `confkey:"port" positive:"true"`
+	Name     *string        `confkey:"name"`
+	Enabled  *bool          `confkey:"enabled" aliases:"!disabled"`
+	Interval *time.Duration `confkey:"interval" type:"duration"`
+}
+
+type Protocol string
+
+func (p *Protocol) SetConfkey(v string) error {
+	switch strings.ToLower(v) {
+	case "tcp", "udp":
+		*p = Protocol(strings.ToUpper(v))
+		return nil
+	case "quic":
+		*p = "QUIC"
+		return nil
+	}
+
+	return fmt.Errorf("unknown protocol %s", v)
+}
+
+type SetterData struct {
+	Protocol Protocol `confkey:"protocol" validate:"maxlength=3"`
+}
+
+type StrictData struct {
+	Servers []string      `confkey:"servers" type:"comma_spit"`
+	Names   []string      `confkey:"names" type:"comma_split"`
+	Wait    time.Duration `confkey:"wait" type:"comma_split"`
+	Home    string        `confkey:"home" type:"title_string" expand:"true"`
+}
+
+type UnsupportedData struct {
+	Complex complex128        `confkey:"complex"`
+	Labels  map[string]string `confkey:"labels"`
+	Opts    InlineOptions     `confkey:"opts"`
+}
+
+type SeparatorData struct {
+	Pipes    []string `confkey:"pipes" type:"split" separator:"|"`
+	Override []string `confkey:"override" type:"comma_split" separator:"::"`
+}
+
+type BytesData struct {
+	MaxBody int   `confkey:"max_body" type:"bytes"`
+	Cache   int64 `confkey:"cache" type:"bytes" positive:"true"`
+}
+
+type EmbeddedTLS struct {
+	CA     string `confkey:"ca" default:"/etc/ca.pem"`
+	Verify bool   `confkey:"verify"`
+	Name   string `confkey:"tls_name"`
+}
+
+type EmbeddedData struct {
+	EmbeddedTLS
+
+	Name string `confkey:"name"`
+}
+
+type FilePathData struct {
+	KeyDir  string   `confkey:"keydir" type:"filepath"`
+	Dirs    []string `confkey:"dirs" type:"filepath"`
+	Plugins []string `confkey:"plugins" type:"filepath" separator:","`
+}
+
+type URLData struct {
+	Endpoint string    `confkey:"endpoint" type:"url"`
+	API      *url.URL  `confkey:"api" type:"url"`
+	Base     url.URL   `confkey:"base" type:"url"`
+	Other    time.Time `confkey:"other" type:"url"`
+}
+
+type BadDefaultData struct {
+	Level string `confkey:"level" validate:"enum=debug,info" default:"trace"`
+}
+
+type MergeData struct {
+	Loglevel string    `confkey:"loglevel"`
+	Servers  []string  `confkey:"servers" type:"comma_split"`
+	Port     int       `confkey:"port"`
+	Debug    bool      `confkey:"debug" mergeable:"always"`
+	TLS      NestedTLS `confkey:"tls"`
+}
+
+type EnvAppendData struct {
+	Servers []string `confkey:"servers" type:"comma_split" environment:"EXTRA_SERVERS" env_append:"true"`
+	Replace []string `confkey:"replace" type:"comma_split" environment:"REPLACE_SERVERS"`
+}
+
+type MutexData struct {
+	InlineCert string `confkey:"inline_cert" mutex:"cert"`
+	CertFile   string `confkey:"cert_file" mutex:"cert"`
+}
+
+type InlineOptions struct {
+	Retries int           `confkey:"retries"`
+	Timeout time.Duration `confkey:"timeout" type:"duration"`
+}
+
+type InlineData struct {
+	Opts InlineOptions `confkey:"opts" type:"inline"`
+}
+
+type AliasData struct {
+	Feature bool   `confkey:"feature" aliases:"enable_feature,!no_feature" environment:"ALIAS_FEATURE"`
+	Name    string `confkey:"name" aliases:"title,!untitled"`
+}
+
+type ReloadData struct {
+	Loglevel string   `confkey:"loglevel" default:"warn"`
+	Servers  []string `confkey:"servers" type:"comma_split"`
+	Handle   string   `confkey:"-"`
+	Runtime  int
+}
+
+type DurationListData struct {
+	Retries []time.Duration `confkey:"retries" type:"comma_split" default:"1s,2s,4s"`
+}
+
+type RequiredIfData struct {
+	Mode string `confkey:"mode"`
+	Bind string `confkey:"bind" required_if:"mode=server"`
+}
+
+type EnvAppendUntaggedData struct {
+	Items []string `confkey:"items" environment:"EXTRA_ITEMS" env_append:"true"`
+}
+
+type NamedLevel string
+
+type NamedCount int
+
+type NamedSwitch bool
+
+type NamedTimeout time.Duration
+
+type NamedTypesData struct {
+	Level   NamedLevel   `confkey:"level" type:"lower_string"`
+	Count   NamedCount   `confkey:"count"`
+	Enabled NamedSwitch  `confkey:"enabled"`
+	Timeout NamedTimeout `confkey:"timeout" type:"duration"`
+}
+
+type LockedData struct {
+	Name     string     `confkey:"name" validate:"shellsafe"`
+	Ignored  NestedTLS  `confkey:"-"`
+	mu       sync.Mutex `confkey:"mu"`
+	internal NestedTLS
+}
+
+type RequiredData struct {
+	Token   string      `confkey:"token" required:"true"`
+	Port    int         `confkey:"port" required:"true"`
+	Servers []string    `confkey:"servers" type:"comma_split" required:"true"`
+	TLS     RequiredTLS `confkey:"tls"`
+}
+
+type RequiredTLS struct {
+	CA string `confkey:"ca" required:"true"`
+}
+
+type MatchData struct {
+	Name string `confkey:"name" match:"^[a-z][a-z0-9-]*$"`
+	Bad  string `confkey:"bad" match:"^[a-z"`
+}
+
+type NestedTLS struct {
+	CA   string `confkey:"ca" validate:"shellsafe"`
+	Mode string `confkey:"mode" validate:"enum=verify,none"`
+}
+
+type NestedShared struct {
+	Name string `confkey:"name" validate:"shellsafe"`
+}
+
+type NestedData struct {
+	NestedShared
+
+	TLS NestedTLS `confkey:"tls"`
+}
+
+type LookupData struct {
+	Name    string   `confkey:"name" default:""`
+	Other   string   `confkey:"other"`
+	Port    int      `confkey:"port"`
+	Big     int64    `confkey:"big"`
+	Enabled bool     `confkey:"enabled" default:"false"`
+	Servers []string `confkey:"servers" type:"comma_split"`
+}
+
+type WritableData struct {
+	Dir string `confkey:"dir" validate:"writable"`
+}
+
+type NumberData struct {
+	Int   int     `confkey:"int"`
+	Int64 int64   `confkey:"int64"`
+	Uint  uint    `confkey:"uint"`
+	Float float64 `confkey:"float"`
+	Name  string  `confkey:"name"`
+}
+
+type PrefixDefaultsData struct {
+	Loglevel string `confkey:"loglevel" default:"warn"`
+	CA       string `confkey:"tls.ca" default:"/etc/ca.pem"`
+	Verify   bool   `confkey:"tls.verify" default:"true"`
+}
+
+type NestedDefaultsData struct {
+	Loglevel string            `confkey:"loglevel" default:"warn"`
+	TLS      NestedDefaultsTLS `confkey:"tls"`
+}
+
+type NestedDefaultsTLS struct {
+	CA     string `confkey:"ca" default:"/etc/ca.pem"`
+	Verify bool   `confkey:"verify" default:"true"`
+	Cert   string `confkey:"cert"`
+}
+
+type DefaultSeparatorData struct {
+	Path  []string `confkey:"path" type:"path_split" default:"/bin,/usr/bin" default_separator:","`
+	Items []string `confkey:"items" default:"one|two" default_separator:"|"`
+}
+
 var _ = Describe("Confkey", func() {
 	var d TestData
 
@@ -37,6 +323,122 @@ var _ = Describe("Confkey", func() {
 		d = TestData{}
 	})
 
+	var _ = Describe("Getters", func() {
+		It("Should panic with a clear message when not given a pointer", func() {
+			Expect(panicMessage(func() { IntWithKey(d, "int") })).To(Equal("confkey: a pointer to a struct is required, got confkey.TestData"))
+			Expect(panicMessage(func() { StringFieldWithKey(nil, "plain_string") })).To(Equal("confkey: a pointer to a struct is required, got nil"))
+
+			var nilp *TestData
+			Expect(panicMessage(func() { BoolWithKey(nilp, "bool") })).To(Equal("confkey: a pointer to a struct is required, got *confkey.TestData"))
+		})
+	})
+
+	var _ = Describe("UintWithKey", func() {
+		It("Should get the right uint", func() {
+			Expect(UintWithKey(&d, "uint")).To(Equal(uint(0)))
+			d.Uint = 10
+			Expect(UintWithKey(&d, "uint")).To(Equal(uint(10)))
+		})
+
+		It("Should be 0 when not found", func() {
+			Expect(UintWithKey(&d, "unknown")).To(Equal(uint(0)))
+			Expect(UintWithKey(&d, "uint64")).To(Equal(uint(0)))
+		})
+	})
+
+	var _ = Describe("Uint64WithKey", func() {
+		It("Should get the right uint64", func() {
+			Expect(Uint64WithKey(&d, "uint64")).To(Equal(uint64(0)))
+			d.Uint64 = 10
+			Expect(Uint64WithKey(&d, "uint64")).To(Equal(uint64(10)))
+		})
+
+		It("Should be 0 when not found", func() {
+			Expect(Uint64WithKey(&d, "unknown")).To(Equal(uint64(0)))
+			Expect(Uint64WithKey(&d, "uint")).To(Equal(uint64(0)))
+		})
+	})
+
+	var _ = Describe("FloatWithKey", func() {
+		It("Should get the right float", func() {
+			Expect(FloatWithKey(&d, "ratio")).To(Equal(0.0))
+			d.Ratio = 0.5
+			Expect(FloatWithKey(&d, "ratio")).To(Equal(0.5))
+			d.Ratio32 = 0.25
+			Expect(FloatWithKey(&d, "ratio32")).To(Equal(0.25))
+		})
+
+		It("Should be 0 when not found", func() {
+			Expect(FloatWithKey(&d, "unknown")).To(Equal(0.0))
+			Expect(FloatWithKey(&d, "int")).To(Equal(0.0))
+		})
+	})
+
+	var _ = Describe("NumberWithKey", func() {
+		It("Should read any numeric field", func() {
+			n := NumberData{Int: 1, Int64: 2, Uint: 3, Float: 1.5}
+
+			for k, v := range map[string]float64{"int": 1, "int64": 2, "uint": 3, "float": 1.5} {
+				f, ok := NumberWithKey(&n, k)
+				Expect(ok).To(BeTrue())
+				Expect(f).To(Equal(v))
+			}
+		})
+
+		It("Should be false for non numeric and unknown fields", func() {
+			n := NumberData{}
+
+			_, ok := NumberWithKey(&n, "name")
+			Expect(ok).To(BeFalse())
+
+			_, ok = NumberWithKey(&n, "unknown")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	var _ = Describe("Lookup", func() {
+		It("Should distinguish present but empty fields from unknown keys", func() {
+			l := LookupData{}
+
+			v, ok := LookupString(&l, "name")
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal(""))
+
+			_, ok = LookupString(&l, "unknown")
+			Expect(ok).To(BeFalse())
+
+			_, ok = LookupString(&l, "other")
+			Expect(ok).To(BeFalse())
+
+			_, ok = LookupString(&l, "port")
+			Expect(ok).To(BeFalse())
+
+			b, ok := LookupBool(&l, "enabled")
+			Expect(ok).To(BeTrue())
+			Expect(b).To(BeFalse())
+		})
+
+		It("Should retrieve set values", func() {
+			l := LookupData{Other: "x", Port: 10, Big: 20, Servers: []string{"a"}}
+
+			s, ok := LookupString(&l, "other")
+			Expect(ok).To(BeTrue())
+			Expect(s).To(Equal("x"))
+
+			i, ok := LookupInt(&l, "port")
+			Expect(ok).To(BeTrue())
+			Expect(i).To(Equal(10))
+
+			i64, ok := LookupInt64(&l, "big")
+			Expect(ok).To(BeTrue())
+			Expect(i64).To(Equal(int64(20)))
+
+			list, ok := LookupStringList(&l, "servers")
+			Expect(ok).To(BeTrue())
+			Expect(list).To(Equal([]string{"a"}))
+		})
+	})
+
 	var _ = Describe("Int64WithKey", func() {
 		It("Should get the right int64", func() {
 			Expect(Int64WithKey(&d, "int64")).To(Equal(int64(0)))
@@ -49,6 +451,57 @@ var _ = Describe("Confkey", func() {
 		})
 	})
 
+	var _ = Describe("DurationWithKey", func() {
+		It("Should get the right duration", func() {
+			Expect(DurationWithKey(&d, "interval")).To(Equal(time.Duration(0)))
+			d.T = time.Minute
+			Expect(DurationWithKey(&d, "interval")).To(Equal(time.Minute))
+
+			d.Int64 = 10
+			Expect(DurationWithKey(&d, "int64")).To(Equal(time.Duration(10)))
+		})
+
+		It("Should be 0 when not found", func() {
+			Expect(DurationWithKey(&d, "unknown")).To(Equal(time.Duration(0)))
+			Expect(DurationWithKey(&d, "plain_string")).To(Equal(time.Duration(0)))
+		})
+	})
+
+	var _ = Describe("pointer getters", func() {
+		It("Should dereference pointer fields", func() {
+			pd := PointerData{}
+			Expect(IntWithKey(&pd, "port")).To(Equal(0))
+			Expect(StringFieldWithKey(&pd, "name")).To(Equal(""))
+			Expect(BoolWithKey(&pd, "enabled")).To(BeFalse())
+			Expect(DurationWithKey(&pd, "interval")).To(Equal(time.Duration(0)))
+
+			port := 10
+			name := "test"
+			enabled := true
+			interval := time.Minute
+			pd = PointerData{Port: &port, Name: &name, Enabled: &enabled, Interval: &interval}
+			Expect(IntWithKey(&pd, "port")).To(Equal(10))
+			Expect(StringFieldWithKey(&pd, "name")).To(Equal("test"))
+			Expect(BoolWithKey(&pd, "enabled")).To(BeTrue())
+			Expect(DurationWithKey(&pd, "interval")).To(Equal(time.Minute))
+		})
+	})
+
+	var _ = Describe("TimeWithKey", func() {
+		It("Should get the right time", func() {
+			td := TimeData{}
+			Expect(TimeWithKey(&td, "day").IsZero()).To(BeTrue())
+
+			td.Day = time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+			Expect(TimeWithKey(&td, "day")).To(Equal(td.Day))
+		})
+
+		It("Should be the zero time when not found", func() {
+			Expect(TimeWithKey(&d, "unknown").IsZero()).To(BeTrue())
+			Expect(TimeWithKey(&d, "plain_string").IsZero()).To(BeTrue())
+		})
+	})
+
 	var _ = Describe("IntWithKey", func() {
 		It("Should get the right int", func() {
 			Expect(IntWithKey(&d, "int")).To(Equal(0))
@@ -74,6 +527,22 @@ var _ = Describe("Confkey", func() {
 		})
 	})
 
+	var _ = Describe("BoolFromIntWithKey", func() {
+		It("Should get the right bool", func() {
+			d.Int = 1
+			Expect(BoolFromIntWithKey(&d, "int")).To(BeTrue())
+			d.Int = 0
+			Expect(BoolFromIntWithKey(&d, "int")).To(BeFalse())
+			d.Int64 = 2
+			Expect(BoolFromIntWithKey(&d, "int64")).To(BeTrue())
+		})
+
+		It("Should be false when not found", func() {
+			Expect(BoolFromIntWithKey(&d, "unknown")).To(BeFalse())
+			Expect(BoolFromIntWithKey(&d, "plain_string")).To(BeFalse())
+		})
+	})
+
 	var _ = Describe("StringListWithKey", func() {
 		It("Should get the right list", func() {
 			d.CommaSplit = []string{"one", "two"}
@@ -85,94 +554,1275 @@ var _ = Describe("Confkey", func() {
 		})
 	})
 
-	var _ = Describe("StringFieldWithKey", func() {
-		It("Should get the right string", func() {
-			d.StringEnum = "warn"
-			Expect(StringFieldWithKey(&d, "loglevel")).To(Equal("warn"))
+	var _ = Describe("Keys", func() {
+		It("Should list all the keys", func() {
+			keys, err := Keys(&ReloadData{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(Equal([]string{"loglevel", "servers"}))
+
+			keys, err = Keys(NestedData{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(Equal([]string{"name", "tls.ca", "tls.mode"}))
 		})
-		It("Should be empty when not found", func() {
-			Expect(StringFieldWithKey(&d, "foo")).To(Equal(""))
-			Expect(StringFieldWithKey(&d, "loglevel")).To(Equal(""))
+
+		It("Should require a struct", func() {
+			_, err := Keys("x")
+			Expect(err).To(MatchError("struct is required"))
 		})
 	})
 
-	var _ = Describe("Validate", func() {
-		It("Should validate the struct", func() {
-			err := Validate(TestData{PlainString: "un > safe"})
-			Expect(err).To(MatchError("PlainString shellsafe validation failed: may not contain '>'"))
+	var _ = Describe("KeyForField", func() {
+		It("Should find the confkey", func() {
+			key, err := KeyForField(&d, "StringEnum")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(Equal("loglevel"))
+
+			key, err = KeyForField(NestedData{}, "Name")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(key).To(Equal("name"))
+		})
+
+		It("Should fail for unknown or untagged fields", func() {
+			_, err := KeyForField(&d, "Missing")
+			Expect(err).To(MatchError("can't find any structure element named 'Missing'"))
+
+			_, err = KeyForField(&ReloadData{}, "Runtime")
+			Expect(err).To(MatchError("structure element 'Runtime' has no confkey"))
+
+			_, err = KeyForField(&ReloadData{}, "Handle")
+			Expect(err).To(MatchError("structure element 'Handle' has no confkey"))
 		})
 	})
 
-	var _ = Describe("SetStructDefaults", func() {
-		It("Should set defaults", func() {
-			err := SetStructDefaults(d)
-			Expect(err).To(MatchError("pointer is required"))
+	var _ = Describe("Merge", func() {
+		It("Should overlay set fields", func() {
+			base := MergeData{Loglevel: "info", Servers: []string{"a", "b"}, Port: 80, Debug: true, TLS: NestedTLS{CA: "/ca.pem", Mode: "verify"}}
+			override := MergeData{Servers: []string{"c"}, TLS: NestedTLS{Mode: "none"}}
 
-			err = SetStructDefaults(&d)
-			Expect(d.StringEnum).To(Equal("warn"))
-			Expect(d.PlainString).To(Equal(""))
-			Expect(d.T).To(Equal(time.Hour))
+			err := Merge(&base, override)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(base).To(Equal(MergeData{Loglevel: "info", Servers: []string{"c"}, Port: 80, Debug: false, TLS: NestedTLS{CA: "/ca.pem", Mode: "none"}}))
+		})
+
+		It("Should validate the arguments", func() {
+			base := MergeData{}
+			Expect(Merge(base, base)).To(MatchError("pointer is required"))
+			Expect(Merge(&base, d)).To(MatchError("cannot merge confkey.TestData into confkey.MergeData"))
 		})
 	})
 
-	var _ = Describe("SetStructFieldWithKey", func() {
-		It("Should set and validate the field", func() {
-			err := SetStructFieldWithKey(&d, "plain_string", "hello world")
+	var _ = Describe("Clone", func() {
+		It("Should deep copy the struct", func() {
+			port := 10
+			orig := &MergeData{Loglevel: "info", Servers: []string{"a", "b"}, TLS: NestedTLS{CA: "/ca.pem"}}
+			md := &MapData{Labels: map[string]string{"a": "b"}}
+			pd := PointerData{Port: &port}
+
+			c, err := Clone(orig)
 			Expect(err).ToNot(HaveOccurred())
+			clone := c.(*MergeData)
+			Expect(clone).To(Equal(orig))
 
-			err = SetStructFieldWithKey(&d, "plain_string", "un > safe")
-			Expect(err).To(MatchError("PlainString shellsafe validation failed: may not contain '>'"))
+			clone.Servers[0] = "x"
+			clone.TLS.CA = "/other.pem"
+			Expect(orig.Servers).To(Equal([]string{"a", "b"}))
+			Expect(orig.TLS.CA).To(Equal("/ca.pem"))
+
+			c, err = Clone(md)
+			Expect(err).ToNot(HaveOccurred())
+			c.(*MapData).Labels["a"] = "c"
+			Expect(md.Labels).To(Equal(map[string]string{"a": "b"}))
+
+			c, err = Clone(pd)
+			Expect(err).ToNot(HaveOccurred())
+			*c.(PointerData).Port = 20
+			Expect(port).To(Equal(10))
 		})
 
-		It("Should handle unknown fields", func() {
-			err := SetStructFieldWithKey(&d, "missing", "hello world")
-			Expect(err).To(MatchError("can't find any structure element configured with confkey 'missing'"))
+		It("Should require a struct", func() {
+			_, err := Clone("x")
+			Expect(err).To(MatchError("struct is required"))
 		})
+	})
 
-		It("Should support comma_split", func() {
-			err := SetStructFieldWithKey(&d, "comma_split", "foo, bar, baz")
+	var _ = Describe("ReloadInto", func() {
+		It("Should only copy confkey fields", func() {
+			running := ReloadData{Loglevel: "debug", Servers: []string{"a"}, Handle: "h", Runtime: 10}
+			fresh := ReloadData{Loglevel: "info", Servers: []string{"b", "c"}}
+
+			err := ReloadInto(&running, &fresh)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(d.CommaSplit).To(Equal([]string{"foo", "bar", "baz"}))
+			Expect(running).To(Equal(ReloadData{Loglevel: "info", Servers: []string{"b", "c"}, Handle: "h", Runtime: 10}))
 		})
 
-		It("Should support colon_split", func() {
-			err := SetStructFieldWithKey(&d, "colon_split", "/foo:/bar:/baz")
+		It("Should validate the arguments", func() {
+			running := ReloadData{}
+			Expect(ReloadInto(running, running)).To(MatchError("pointer is required"))
+			Expect(ReloadInto(&running, d)).To(MatchError("cannot reload confkey.ReloadData from confkey.TestData"))
+		})
 
-			Expect(err).ToNot(HaveOccurred())
-			Expect(d.ColonSplit).To(Equal([]string{"/foo", "/bar", "/baz"}))
+		It("Should not match excluded fields", func() {
+			err := SetStructFieldWithKey(&ReloadData{}, "-", "x")
+			Expect(err).To(MatchError("can't find any structure element configured with confkey '-'"))
 		})
+	})
 
-		It("Should support path_split", func() {
-			var err error
+	var _ = Describe("fallback getters", func() {
+		It("Should return the value when found", func() {
+			d.PlainString = "x"
+			d.CommaSplit = []string{"a"}
+			d.Bool = true
+			d.Int = 1
+			d.Int64 = 2
+			d.Ratio = 0.5
+			d.Ratio32 = 0.25
+			d.T = time.Minute
 
-			if runtime.GOOS == "windows" {
-				err = SetStructFieldWithKey(&d, "path_split", "/foo;/bar;/baz")
-			} else {
-				err = SetStructFieldWithKey(&d, "path_split", "/foo:/bar:/baz")
+			Expect(StringFieldWithKeyOr(&d, "plain_string", "y")).To(Equal("x"))
+			Expect(StringListWithKeyOr(&d, "comma_split", []string{"b"})).To(Equal([]string{"a"}))
+			Expect(BoolWithKeyOr(&d, "bool", false)).To(BeTrue())
+			Expect(IntWithKeyOr(&d, "int", 10)).To(Equal(1))
+			Expect(Int64WithKeyOr(&d, "int64", 10)).To(Equal(int64(2)))
+			Expect(FloatWithKeyOr(&d, "ratio", 10)).To(Equal(0.5))
+			Expect(FloatWithKeyOr(&d, "ratio32", 10)).To(Equal(0.25))
+			Expect(DurationWithKeyOr(&d, "interval", time.Hour)).To(Equal(time.Minute))
+
+			Expect(IntWithKeyOr(&d, "count", 10)).To(Equal(0))
+		})
+
+		It("Should return the fallback when missing or of another kind", func() {
+			Expect(StringFieldWithKeyOr(&d, "missing", "y")).To(Equal("y"))
+			Expect(StringFieldWithKeyOr(&d, "int", "y")).To(Equal("y"))
+			Expect(StringListWithKeyOr(&d, "missing", []string{"b"})).To(Equal([]string{"b"}))
+			Expect(StringListWithKeyOr(&DurationListData{}, "retries", []string{"b"})).To(Equal([]string{"b"}))
+			Expect(BoolWithKeyOr(&d, "missing", true)).To(BeTrue())
+			Expect(IntWithKeyOr(&d, "int64", 10)).To(Equal(10))
+			Expect(Int64WithKeyOr(&d, "missing", 10)).To(Equal(int64(10)))
+			Expect(FloatWithKeyOr(&d, "int", 10)).To(Equal(float64(10)))
+			Expect(DurationWithKeyOr(&d, "missing", time.Hour)).To(Equal(time.Hour))
+		})
+	})
+
+	var _ = Describe("KindForKey", func() {
+		It("Should get the kind", func() {
+			for key, kind := range map[string]reflect.Kind{"plain_string": reflect.String, "comma_split": reflect.Slice, "interval": reflect.Int64, "bool": reflect.Bool, "ratio": reflect.Float64} {
+				k, err := KindForKey(&d, key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(k).To(Equal(kind))
 			}
 
+			k, err := KindForKey(&PointerData{}, "port")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(d.PathSplit).To(Equal([]string{"/foo", "/bar", "/baz"}))
-		})
+			Expect(k).To(Equal(reflect.Int))
 
-		It("Should support ints", func() {
-			err := SetStructFieldWithKey(&d, "int", "1")
+			k, err = KindForKey(&NestedData{}, "tls.ca")
 			Expect(err).ToNot(HaveOccurred())
-			Expect(d.Int).To(Equal(1))
+			Expect(k).To(Equal(reflect.String))
 		})
 
-		It("Should support title_string", func() {
-			err := SetStructFieldWithKey(&d, "title_string", "foobar")
-			Expect(err).ToNot(HaveOccurred())
-			Expect(d.TitleString).To(Equal("Foobar"))
+		It("Should fail for unknown keys", func() {
+			k, err := KindForKey(&d, "missing")
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+			Expect(k).To(Equal(reflect.Invalid))
 		})
 
-		It("Should support path_string", func() {
-			err := os.Setenv("HOME", "/home/joeuser")
-			Expect(err).ToNot(HaveOccurred())
-			err = os.Setenv("HOMEDRIVE", "C:\\")
+		It("Should require a pointer", func() {
+			k, err := KindForKey(d, "int")
+			Expect(err).To(MatchError("pointer is required"))
+			Expect(k).To(Equal(reflect.Invalid))
+		})
+	})
+
+	var _ = Describe("Get", func() {
+		It("Should get the typed value", func() {
+			d.CommaSplit = []string{"a", "b"}
+			d.T = time.Minute
+			d.Int = 10
+
+			servers, err := Get[[]string](&d, "comma_split")
 			Expect(err).ToNot(HaveOccurred())
-			err = os.Setenv("HOMEDIR", "myhome")
+			Expect(servers).To(Equal([]string{"a", "b"}))
+
+			interval, err := Get[time.Duration](&d, "interval")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(interval).To(Equal(time.Minute))
+
+			i, err := Get[int](&d, "int")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(i).To(Equal(10))
+		})
+
+		It("Should require a pointer", func() {
+			_, err := Get[int](d, "int")
+			Expect(err).To(MatchError("pointer is required"))
+
+			_, err = Get[int](nil, "int")
+			Expect(err).To(MatchError("pointer is required"))
+		})
+
+		It("Should fail for the wrong type", func() {
+			_, err := Get[string](&d, "int")
+			Expect(err).To(MatchError("int is int, not string"))
+		})
+
+		It("Should fail for unknown keys", func() {
+			_, err := Get[string](&d, "missing")
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+		})
+	})
+
+	var _ = Describe("StringFieldWithKey", func() {
+		It("Should get the right string", func() {
+			d.StringEnum = "warn"
+			Expect(StringFieldWithKey(&d, "loglevel")).To(Equal("warn"))
+		})
+		It("Should be empty when not found", func() {
+			Expect(StringFieldWithKey(&d, "foo")).To(Equal(""))
+			Expect(StringFieldWithKey(&d, "loglevel")).To(Equal(""))
+		})
+	})
+
+	var _ = Describe("Validate", func() {
+		It("Should validate the struct", func() {
+			err := Validate(TestData{PlainString: "un > safe"})
+			Expect(err).To(MatchError("PlainString shellsafe validation failed: may not contain '>'"))
+		})
+
+		It("Should validate mutex groups", func() {
+			m := MutexData{InlineCert: "x"}
+			Expect(Validate(m)).ToNot(HaveOccurred())
+
+			m.CertFile = "/cert.pem"
+			Expect(Validate(&m)).To(MatchError("only one of the keys in mutex group 'cert' may be set, found inline_cert, cert_file"))
+		})
+
+		It("Should skip unexported and ignored fields", func() {
+			l := LockedData{Name: "x"}
+			Expect(Validate(&l)).ToNot(HaveOccurred())
+			Expect(ValidateAll(&l)).To(BeEmpty())
+
+			l.Name = "un > safe"
+			Expect(Validate(&l)).To(MatchError("Name shellsafe validation failed: may not contain '>'"))
+		})
+	})
+
+	var _ = Describe("ValidateAll", func() {
+		It("Should report every failure", func() {
+			errs := ValidateAll(TestData{PlainString: "un > safe", StringEnum: "fail"})
+			Expect(errs).To(HaveLen(2))
+			Expect(errs[0]).To(MatchError("plain_string: PlainString shellsafe validation failed: may not contain '>'"))
+			Expect(errs[1]).To(MatchError("loglevel: StringEnum enum validation failed: 'fail' is not in the allowed list: debug, info, warn"))
+		})
+
+		It("Should include nested structs and checks", func() {
+			n := NestedData{TLS: NestedTLS{CA: "/ca.pem > x", Mode: "other"}}
+			n.Name = "un > safe"
+
+			errs := ValidateAll(&n)
+			Expect(errs).To(HaveLen(3))
+			Expect(errs[0]).To(MatchError("name: Name shellsafe validation failed: may not contain '>'"))
+			Expect(errs[1]).To(MatchError("tls.ca: CA shellsafe validation failed: may not contain '>'"))
+			Expect(errs[2]).To(MatchError("tls.mode: Mode enum validation failed: 'other' is not in the allowed list: verify, none"))
+
+			errs = ValidateAll(MutexData{InlineCert: "x", CertFile: "/cert.pem"})
+			Expect(errs).To(HaveLen(1))
+			Expect(errs[0]).To(MatchError("only one of the keys in mutex group 'cert' may be set, found inline_cert, cert_file"))
+		})
+
+		It("Should be empty when valid", func() {
+			Expect(ValidateAll(TestData{StringEnum: "warn"})).To(BeEmpty())
+		})
+	})
+
+	var _ = Describe("nested validation", func() {
+		It("Should validate nested structs with dotted keys", func() {
+			n := NestedData{TLS: NestedTLS{CA: "/ca.pem", Mode: "none"}}
+			Expect(Validate(n)).ToNot(HaveOccurred())
+
+			n.TLS.CA = "/ca.pem > x"
+			Expect(Validate(n)).To(MatchError("tls.ca: CA shellsafe validation failed: may not contain '>'"))
+
+			n.TLS.CA = "/ca.pem"
+			n.TLS.Mode = "other"
+			Expect(Validate(&n)).To(MatchError("tls.mode: Mode enum validation failed: 'other' is not in the allowed list: verify, none"))
+		})
+
+		It("Should validate embedded structs", func() {
+			n := NestedData{TLS: NestedTLS{Mode: "none"}}
+			n.Name = "un > safe"
+			Expect(Validate(n)).To(MatchError("Name shellsafe validation failed: may not contain '>'"))
+		})
+	})
+
+	var _ = Describe("writable", func() {
+		var td string
+
+		BeforeEach(func() {
+			var err error
+			td, err = ioutil.TempDir("", "confkey")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.Chmod(td, 0700)
+			os.RemoveAll(td)
+		})
+
+		It("Should accept writable directories", func() {
+			Expect(Validate(WritableData{Dir: td})).ToNot(HaveOccurred())
+		})
+
+		It("Should fail for missing directories and files", func() {
+			missing := filepath.Join(td, "missing")
+			Expect(Validate(WritableData{Dir: missing})).To(MatchError(fmt.Sprintf("Dir writable validation failed: stat %s: no such file or directory", missing)))
+
+			file := filepath.Join(td, "file")
+			Expect(ioutil.WriteFile(file, []byte("x"), 0600)).To(Succeed())
+			Expect(Validate(WritableData{Dir: file})).To(MatchError(fmt.Sprintf("Dir writable validation failed: %s is not a directory", file)))
+		})
+
+		It("Should fail for read-only directories", func() {
+			if runtime.GOOS == "windows" || os.Getuid() == 0 {
+				Skip("permissions are not enforced for this user")
+			}
+
+			Expect(os.Chmod(td, 0500)).To(Succeed())
+			Expect(Validate(WritableData{Dir: td})).To(HaveOccurred())
+		})
+
+		It("Should support downgrading to warnings", func() {
+			var warnings []error
+			SetWritableWarner(func(err error) { warnings = append(warnings, err) })
+			defer SetWritableWarner(nil)
+
+			file := filepath.Join(td, "file")
+			Expect(ioutil.WriteFile(file, []byte("x"), 0600)).To(Succeed())
+			Expect(Validate(WritableData{Dir: file})).ToNot(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0]).To(MatchError(fmt.Sprintf("Dir writable validation failed: %s is not a directory", file)))
+		})
+	})
+
+	var _ = Describe("required_if", func() {
+		It("Should require fields based on other fields", func() {
+			r := RequiredIfData{Mode: "client"}
+			Expect(Validate(r)).ToNot(HaveOccurred())
+
+			r.Mode = "server"
+			Expect(Validate(r)).To(MatchError("bind is required when mode is server"))
+
+			r.Bind = "0.0.0.0"
+			Expect(Validate(&r)).ToNot(HaveOccurred())
+		})
+	})
+
+	var _ = Describe("required", func() {
+		It("Should require fields to be set", func() {
+			r := RequiredData{}
+			Expect(Validate(r)).To(MatchError("tls: ca is required"))
+			Expect(ValidateAll(&r)).To(Equal([]error{errors.New("tls: ca is required"), errors.New("token is required")}))
+
+			r.TLS.CA = "ca.pem"
+			Expect(Validate(r)).To(MatchError("token is required"))
+
+			r.Token = "x"
+			r.Port = 80
+			Expect(Validate(r)).To(MatchError("servers is required"))
+
+			r.Servers = []string{"a"}
+			Expect(Validate(&r)).ToNot(HaveOccurred())
+		})
+	})
+
+	var _ = Describe("ValidateUnique", func() {
+		It("Should detect duplicates", func() {
+			items := []TestData{{PlainString: "one"}, {PlainString: "two"}}
+			Expect(ValidateUnique(items, "plain_string")).ToNot(HaveOccurred())
+
+			items = append(items, TestData{PlainString: "one"})
+			Expect(ValidateUnique(items, "plain_string")).To(MatchError("duplicate value 'one' found for key 'plain_string'"))
+			Expect(ValidateUnique([]*TestData{&items[0], &items[2]}, "plain_string")).To(MatchError("duplicate value 'one' found for key 'plain_string'"))
+		})
+
+		It("Should handle bad input", func() {
+			Expect(ValidateUnique(d, "plain_string")).To(MatchError("slice is required"))
+			Expect(ValidateUnique([]string{"x"}, "plain_string")).To(MatchError("slice of structs is required"))
+			Expect(ValidateUnique([]TestData{d}, "missing")).To(MatchError("can't find any structure element configured with confkey 'missing'"))
+		})
+	})
+
+	var _ = Describe("SetStructDefaultsAndValidate", func() {
+		It("Should set and validate defaults", func() {
+			err := SetStructDefaultsAndValidate(d)
+			Expect(err).To(MatchError("pointer is required"))
+
+			err = SetStructDefaultsAndValidate(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("warn"))
+		})
+
+		It("Should fail on invalid defaults", func() {
+			bd := BadDefaultData{}
+			err := SetStructDefaultsAndValidate(&bd)
+			Expect(err).To(MatchError("Level enum validation failed: 'trace' is not in the allowed list: debug, info"))
+		})
+	})
+
+	var _ = Describe("SetStructDefaults", func() {
+		It("Should set defaults", func() {
+			err := SetStructDefaults(d)
+			Expect(err).To(MatchError("pointer is required"))
+
+			err = SetStructDefaults(&d)
+			Expect(d.StringEnum).To(Equal("warn"))
+			Expect(d.PlainString).To(Equal(""))
+			Expect(d.T).To(Equal(time.Hour))
+		})
+
+		It("Should only set unset fields when requested", func() {
+			d.StringEnum = "debug"
+			d.CommaSplit = []string{"x"}
+
+			err := SetStructDefaultsIfUnset(d)
+			Expect(err).To(MatchError("pointer is required"))
+
+			err = SetStructDefaultsIfUnset(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("debug"))
+			Expect(d.T).To(Equal(time.Hour))
+
+			ds := DefaultSeparatorData{Path: []string{"/sbin"}}
+			err = SetStructDefaultsIfUnset(&ds)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ds.Path).To(Equal([]string{"/sbin"}))
+			Expect(ds.Items).To(Equal([]string{"one", "two"}))
+		})
+
+		It("Should reset to the defaults", func() {
+			d.StringEnum = "debug"
+			d.PlainString = "hello"
+			d.CommaSplit = []string{"a"}
+			d.T = time.Minute
+
+			Expect(ResetDefaults(d)).To(MatchError("pointer is required"))
+
+			err := ResetDefaults(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("warn"))
+			Expect(d.PlainString).To(Equal(""))
+			Expect(d.CommaSplit).To(BeNil())
+			Expect(d.T).To(Equal(time.Hour))
+
+			n := NestedData{TLS: NestedTLS{CA: "/ca.pem"}}
+			n.Name = "x"
+			Expect(ResetDefaults(&n)).To(Succeed())
+			Expect(n).To(Equal(NestedData{}))
+
+			nd := NestedDefaultsData{Loglevel: "debug", TLS: NestedDefaultsTLS{CA: "/other.pem", Verify: false, Cert: "cert.pem"}}
+			Expect(ResetDefaults(&nd)).To(Succeed())
+			Expect(nd).To(Equal(NestedDefaultsData{Loglevel: "warn", TLS: NestedDefaultsTLS{CA: "/etc/ca.pem", Verify: true}}))
+		})
+
+		It("Should set fields from the environment without defaults", func() {
+			os.Setenv("EXTRA_SERVERS", "s3")
+			defer os.Unsetenv("EXTRA_SERVERS")
+			os.Setenv("REPLACE_SERVERS", "s1,s2")
+			defer os.Unsetenv("REPLACE_SERVERS")
+
+			ea := EnvAppendData{}
+			err := SetStructDefaults(&ea)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ea.Servers).To(Equal([]string{"s3"}))
+			Expect(ea.Replace).To(Equal([]string{"s1", "s2"}))
+
+			os.Setenv("EXTRA_ITEMS", "c")
+			defer os.Unsetenv("EXTRA_ITEMS")
+
+			eu := EnvAppendUntaggedData{}
+			err = SetStructDefaults(&eu)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(eu.Items).To(Equal([]string{"c"}))
+
+			os.Setenv("TEST_RATIO", "0.5")
+			defer os.Unsetenv("TEST_RATIO")
+
+			err = SetStructDefaults(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Ratio).To(Equal(0.5))
+		})
+
+		It("Should support duration list defaults", func() {
+			dl := DurationListData{}
+			err := SetStructDefaults(&dl)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dl.Retries).To(Equal([]time.Duration{time.Second, 2 * time.Second, 4 * time.Second}))
+			Expect(StringListWithKey(&dl, "retries")).To(Equal([]string{}))
+		})
+
+		It("Should only set defaults for keys with a prefix when requested", func() {
+			p := PrefixDefaultsData{Loglevel: "debug", CA: "/other.pem"}
+
+			Expect(SetStructDefaultsWithPrefix(p, "tls.")).To(MatchError("pointer is required"))
+			Expect(SetStructDefaultsWithPrefix(&p, "tls.")).ToNot(HaveOccurred())
+			Expect(p).To(Equal(PrefixDefaultsData{Loglevel: "debug", CA: "/etc/ca.pem", Verify: true}))
+
+			nd := NestedDefaultsData{Loglevel: "debug", TLS: NestedDefaultsTLS{CA: "/other.pem"}}
+			Expect(SetStructDefaultsWithPrefix(&nd, "tls.")).ToNot(HaveOccurred())
+			Expect(nd).To(Equal(NestedDefaultsData{Loglevel: "debug", TLS: NestedDefaultsTLS{CA: "/etc/ca.pem", Verify: true}}))
+		})
+
+		It("Should set defaults of nested structs", func() {
+			nd := NestedDefaultsData{}
+			Expect(SetStructDefaults(&nd)).ToNot(HaveOccurred())
+			Expect(nd).To(Equal(NestedDefaultsData{Loglevel: "warn", TLS: NestedDefaultsTLS{CA: "/etc/ca.pem", Verify: true}}))
+
+			nd = NestedDefaultsData{TLS: NestedDefaultsTLS{CA: "/other.pem"}}
+			Expect(SetStructDefaultsIfUnset(&nd)).ToNot(HaveOccurred())
+			Expect(nd.TLS.CA).To(Equal("/other.pem"))
+			Expect(nd.TLS.Verify).To(BeTrue())
+		})
+
+		It("Should support default_separator", func() {
+			ds := DefaultSeparatorData{}
+			err := SetStructDefaults(&ds)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ds.Path).To(Equal([]string{"/bin", "/usr/bin"}))
+			Expect(ds.Items).To(Equal([]string{"one", "two"}))
+		})
+	})
+
+	var _ = Describe("SetFields", func() {
+		It("Should set all the fields", func() {
+			err := SetFields(&d, map[string]string{"loglevel": "info", "int": "10"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("info"))
+			Expect(d.Int).To(Equal(10))
+		})
+
+		It("Should require a pointer", func() {
+			err := SetFields(d, map[string]string{"loglevel": "info"})
+			Expect(err).To(MatchError("pointer is required"))
+		})
+
+		It("Should strip prefixes", func() {
+			err := SetFields(&d, map[string]string{"app.loglevel": "info", "int": "10"}, WithKeyPrefix("app."))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("info"))
+			Expect(d.Int).To(Equal(10))
+		})
+
+		It("Should report keys without the prefix in strict mode", func() {
+			err := SetFields(&d, map[string]string{"app.loglevel": "info", "int": "10"}, WithKeyPrefix("app."), WithStrict())
+			Expect(err).To(MatchError("key 'int' does not have the required prefix 'app.'"))
+		})
+
+		It("Should pass the options to every field", func() {
+			sd := StrictData{}
+			Expect(SetFields(&sd, map[string]string{"servers": "a,b"})).To(Succeed())
+			Expect(SetFields(&sd, map[string]string{"servers": "a,b"}, WithStrict())).To(MatchError("unknown type 'comma_spit' for key 'servers' of kind slice"))
+
+			errs := SetFieldsCollect(&sd, map[string]string{"servers": "a,b", "names": "a,b"}, WithStrict())
+			Expect(errs).To(HaveLen(1))
+			Expect(errs["servers"]).To(MatchError("unknown type 'comma_spit' for key 'servers' of kind slice"))
+
+			os.Setenv("APP_INT", "20")
+			defer os.Unsetenv("APP_INT")
+
+			Expect(SetFields(&d, map[string]string{"int": "10"}, WithEnvPrefix("APP_"))).To(Succeed())
+			Expect(d.Int).To(Equal(20))
+		})
+	})
+
+	var _ = Describe("SetStructFieldsFromMap", func() {
+		It("Should set all the fields", func() {
+			err := SetStructFieldsFromMap(&d, map[string]string{"loglevel": "info", "int": "10"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("info"))
+			Expect(d.Int).To(Equal(10))
+		})
+
+		It("Should report unknown keys", func() {
+			err := SetStructFieldsFromMap(&d, map[string]string{"missing": "1"})
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+
+			errs := SetFieldsCollect(&d, map[string]string{"missing": "1", "int": "one"})
+			Expect(errors.Is(errs["missing"], ErrUnknownKey)).To(BeTrue())
+			Expect(errors.Is(errs["int"], ErrUnknownKey)).To(BeFalse())
+		})
+	})
+
+	var _ = Describe("SetFieldsCollect", func() {
+		It("Should set valid fields and collect all errors", func() {
+			errs := SetFieldsCollect(&d, map[string]string{"loglevel": "fail", "int": "one", "missing": "1", "bool": "true"})
+			Expect(errs).To(HaveLen(3))
+			Expect(errs["loglevel"]).To(MatchError("StringEnum enum validation failed: 'fail' is not in the allowed list: debug, info, warn"))
+			Expect(errs["int"]).To(MatchError(`strconv.ParseInt: parsing "one": invalid syntax`))
+			Expect(errs["missing"]).To(MatchError("can't find any structure element configured with confkey 'missing'"))
+			Expect(d.Bool).To(BeTrue())
+		})
+
+		It("Should be empty on success", func() {
+			errs := SetFieldsCollect(&d, map[string]string{"app.int": "1"}, WithKeyPrefix("app."))
+			Expect(errs).To(BeEmpty())
+			Expect(d.Int).To(Equal(1))
+		})
+
+		It("Should require a pointer", func() {
+			errs := SetFieldsCollect(d, map[string]string{"int": "1"})
+			Expect(errs["int"]).To(MatchError("pointer is required"))
+		})
+	})
+
+	var _ = Describe("SetStructFieldWithKey", func() {
+		It("Should set and validate the field", func() {
+			err := SetStructFieldWithKey(&d, "plain_string", "hello world")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = SetStructFieldWithKey(&d, "plain_string", "un > safe")
+			Expect(err).To(MatchError("PlainString shellsafe validation failed: may not contain '>'"))
+		})
+
+		It("Should handle unknown fields", func() {
+			err := SetStructFieldWithKey(&d, "missing", "hello world")
+			Expect(err).To(MatchError("can't find any structure element configured with confkey 'missing'"))
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+
+			err = SetStructFieldWithKey(&d, "plain_string", "un > safe")
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeFalse())
+		})
+
+		It("Should support comma_split", func() {
+			err := SetStructFieldWithKey(&d, "comma_split", "foo, bar, baz")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{"foo", "bar", "baz"}))
+		})
+
+		It("Should support quoted comma_split items", func() {
+			err := SetStructFieldWithKey(&d, "comma_split", `"a,b", "c" , d ,"say ""hi"""," e "`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{"a,b", "c", "d", `say "hi"`, " e "}))
+
+			err = SetStructFieldWithKey(&d, "comma_split", `a"b,c`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{`a"b`, "c"}))
+
+			err = SetStructFieldWithKey(&d, "comma_split", `"a,b`)
+			Expect(err).To(MatchError(`invalid value for key 'comma_split': unterminated quote in "a,b`))
+
+			err = SetStructFieldWithKey(&d, "comma_split", `"a"b,c`)
+			Expect(err).To(MatchError(`invalid value for key 'comma_split': unexpected text after quoted item "a"`))
+		})
+
+		It("Should support colon_split", func() {
+			err := SetStructFieldWithKey(&d, "colon_split", "/foo:/bar:/baz")
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.ColonSplit).To(Equal([]string{"/foo", "/bar", "/baz"}))
+		})
+
+		It("Should replace slices on every set", func() {
+			for i := 0; i < 2; i++ {
+				var err error
+
+				if runtime.GOOS == "windows" {
+					err = SetStructFieldWithKey(&d, "path_split", "/foo;/bar")
+				} else {
+					err = SetStructFieldWithKey(&d, "path_split", "/foo:/bar")
+				}
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d.PathSplit).To(HaveLen(2))
+
+				err = SetStructFieldWithKey(&d, "colon_split", "/foo:/bar")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d.ColonSplit).To(HaveLen(2))
+
+				err = SetStructFieldWithKey(&d, "untagged", "foo")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d.Untagged).To(Equal([]string{"foo"}))
+			}
+		})
+
+		It("Should support int lists", func() {
+			il := IntListData{}
+
+			err := SetStructFieldWithKey(&il, "ports", "80, 443,8080")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(il.Ports).To(Equal([]int{80, 443, 8080}))
+
+			err = SetStructFieldWithKey(&il, "ports", "80,https")
+			Expect(err).To(MatchError(`invalid ports item 'https': strconv.ParseInt: parsing "https": invalid syntax`))
+			Expect(il.Ports).To(Equal([]int{80, 443, 8080}))
+
+			err = SetStructFieldWithKey(&il, "ports", "0x1F,0o17")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(il.Ports).To(Equal([]int{31, 15}))
+		})
+
+		It("Should support custom separators", func() {
+			sd := SeparatorData{Pipes: []string{"old"}}
+
+			err := SetStructFieldWithKey(&sd, "pipes", " a | b|c ")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sd.Pipes).To(Equal([]string{"a", "b", "c"}))
+
+			err = SetStructFieldWithKey(&sd, "override", "a,b :: c")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sd.Override).To(Equal([]string{"a,b", "c"}))
+
+			m, err := MarshalMap(&sd)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m["pipes"]).To(Equal("a|b|c"))
+		})
+
+		It("Should support duration lists", func() {
+			dl := DurationListData{}
+
+			err := SetStructFieldWithKey(&dl, "retries", "1s, 5, 1m")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dl.Retries).To(Equal([]time.Duration{time.Second, 5 * time.Second, time.Minute}))
+
+			err = SetStructFieldWithKey(&dl, "retries", "1s,soon")
+			Expect(err).To(MatchError(`invalid retries item 'soon': time: invalid duration "soon"`))
+			Expect(dl.Retries).To(Equal([]time.Duration{time.Second, 5 * time.Second, time.Minute}))
+		})
+
+		It("Should support line_split", func() {
+			d.LineSplit = []string{"old"}
+
+			err := SetStructFieldWithKey(&d, "line_split", "10.0.0.1\r\n  10.0.0.2 \n\n\n10.0.0.3\n")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.LineSplit).To(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}))
+
+			text, err := MarshalText(&TestData{StringEnum: "warn", LineSplit: []string{"a", "b"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(text)).To(ContainSubstring("line_split = a\nline_split = b\n"))
+		})
+
+		It("Should support semicolon_split", func() {
+			err := SetStructFieldWithKey(&d, "semicolon_split", "foo; bar ;baz;")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.SemiSplit).To(Equal([]string{"foo", "bar", "baz"}))
+
+			err = SetStructFieldWithKey(&d, "semicolon_split", "one;two")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.SemiSplit).To(Equal([]string{"one", "two"}))
+		})
+
+		It("Should support path_split", func() {
+			var err error
+
+			if runtime.GOOS == "windows" {
+				err = SetStructFieldWithKey(&d, "path_split", "/foo;/bar;/baz")
+			} else {
+				err = SetStructFieldWithKey(&d, "path_split", "/foo:/bar:/baz")
+			}
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.PathSplit).To(Equal([]string{"/foo", "/bar", "/baz"}))
+		})
+
+		It("Should support env_append", func() {
+			ea := EnvAppendData{}
+
+			os.Setenv("EXTRA_SERVERS", "s3")
+			defer os.Unsetenv("EXTRA_SERVERS")
+			os.Setenv("REPLACE_SERVERS", "s3")
+			defer os.Unsetenv("REPLACE_SERVERS")
+
+			err := SetStructFieldWithKey(&ea, "servers", "s1, s2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ea.Servers).To(Equal([]string{"s1", "s2", "s3"}))
+
+			err = SetStructFieldWithKey(&ea, "replace", "s1, s2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ea.Replace).To(Equal([]string{"s3"}))
+		})
+
+		It("Should support environment prefixes", func() {
+			os.Setenv("APP_COMMA_SPLIT", "a,b")
+			defer os.Unsetenv("APP_COMMA_SPLIT")
+			os.Setenv("APP_RATIO", "0.1")
+			defer os.Unsetenv("APP_RATIO")
+			os.Setenv("TEST_RATIO", "0.2")
+			defer os.Unsetenv("TEST_RATIO")
+
+			err := SetStructFieldWithKeyWithEnvPrefix(&d, "comma_split", "x,y", "APP_")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{"a", "b"}))
+
+			err = SetStructFieldWithKeyWithEnvPrefix(&d, "ratio", "0.5", "APP_")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Ratio).To(Equal(0.2))
+
+			err = SetStructFieldWithKeyWithEnvPrefix(&d, "int", "10", "APP_")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Int).To(Equal(10))
+		})
+
+		It("Should expand environment variables when requested", func() {
+			os.Setenv("EXPAND_HOME", "/home/test")
+			defer os.Unsetenv("EXPAND_HOME")
+
+			ed := ExpandData{}
+
+			err := SetStructDefaults(&ed)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ed.Dir).To(Equal("/home/test/.config/app"))
+
+			err = SetStructFieldWithKey(&ed, "dir", "$EXPAND_HOME/other")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ed.Dir).To(Equal("/home/test/other"))
+
+			err = SetStructFieldWithKey(&ed, "literal", "$EXPAND_HOME")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ed.Literal).To(Equal("$EXPAND_HOME"))
+
+			Expect(ed.Paths).To(Equal([]string{"/home/test/a", "/b"}))
+
+			err = SetStructFieldWithKey(&ed, "paths", "${EXPAND_HOME}/x, $EXPAND_HOME/y")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ed.Paths).To(Equal([]string{"/home/test/x", "/home/test/y"}))
+
+			err = SetStructFieldWithKey(&ed, "dirs", "$EXPAND_HOME")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ed.Dirs).To(Equal([]string{"/home/test"}))
+
+			err = SetStructFieldWithKey(&ed, "dirs", []string{"$EXPAND_HOME/z"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ed.Dirs).To(Equal([]string{"/home/test/z"}))
+		})
+
+		It("Should support case insensitive keys", func() {
+			err := SetStructFieldWithKeyCaseInsensitive(&d, "LogLevel", "info")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.StringEnum).To(Equal("info"))
+
+			err = SetStructFieldWithKey(&d, "LogLevel", "info")
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+
+			err = SetStructFieldWithKeyCaseInsensitive(&d, "Missing", "info")
+			Expect(err).To(MatchError("can't find any structure element configured with confkey 'Missing'"))
+
+			cd := CaseData{}
+			err = SetStructFieldWithKeyCaseInsensitive(&cd, "LogLevel", "debug")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cd.Upper).To(Equal("debug"))
+			Expect(cd.Loglevel).To(BeEmpty())
+
+			err = SetStructFieldWithKeyCaseInsensitive(&cd, "LOGLEVEL", "info")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cd.Loglevel).To(Equal("info"))
+		})
+
+		It("Should support pointer fields", func() {
+			pd := PointerData{}
+
+			Expect(SetStructFieldWithKey(&pd, "port", "0")).To(MatchError("port must be greater than 0"))
+			Expect(pd.Port).To(BeNil())
+
+			Expect(SetStructFieldWithKey(&pd, "port", "10")).To(Succeed())
+			Expect(*pd.Port).To(Equal(10))
+
+			Expect(SetStructFieldWithKey(&pd, "name", "")).To(Succeed())
+			Expect(*pd.Name).To(Equal(""))
+
+			Expect(SetStructFieldWithKey(&pd, "disabled", "true")).To(Succeed())
+			Expect(*pd.Enabled).To(BeFalse())
+
+			Expect(SetStructFieldWithKey(&pd, "interval", "1m")).To(Succeed())
+			Expect(*pd.Interval).To(Equal(time.Minute))
+		})
+
+		It("Should support fields implementing Setter", func() {
+			sd := SetterData{}
+
+			Expect(SetStructFieldWithKey(&sd, "protocol", "tcp")).To(Succeed())
+			Expect(sd.Protocol).To(Equal(Protocol("TCP")))
+
+			Expect(SetStructFieldWithKey(&sd, "protocol", "icmp")).To(MatchError("unknown protocol icmp"))
+
+			Expect(SetStructFieldWithKey(&sd, "protocol", "quic")).To(MatchError("Protocol maxlength validation failed: 4 characters, max allowed 3"))
+		})
+
+		It("Should reject unknown type tags in strict mode", func() {
+			sd := StrictData{}
+
+			Expect(SetStructFieldWithKey(&sd, "servers", "a,b")).To(Succeed())
+			Expect(SetStructFieldWithKeyStrict(&sd, "servers", "a,b")).To(MatchError("unknown type 'comma_spit' for key 'servers' of kind slice"))
+			Expect(SetStructFieldWithKeyStrict(&sd, "wait", "1s")).To(MatchError("unknown type 'comma_split' for key 'wait' of kind int64"))
+
+			Expect(SetStructFieldWithKeyStrict(&sd, "names", "a,b")).To(Succeed())
+			Expect(sd.Names).To(Equal([]string{"a", "b"}))
+
+			Expect(SetStructFieldWithKeyStrict(&sd, "home", "x")).To(Succeed())
+			Expect(SetStructFieldWithKeyStrict(&d, "interval", "1s")).To(Succeed())
+			Expect(SetStructFieldWithKeyStrict(&d, "plain_string", "x")).To(Succeed())
+		})
+
+		It("Should fail for unsupported fields", func() {
+			ud := UnsupportedData{}
+
+			Expect(SetStructFieldWithKey(&ud, "complex", "1")).To(MatchError("cannot set Complex for key 'complex', complex128 fields are not supported"))
+			Expect(SetStructFieldWithKey(&ud, "labels", "a=b")).To(MatchError("cannot set Labels for key 'labels', map[string]string fields are not supported"))
+			Expect(SetStructFieldWithKey(&ud, "opts", "retries=1")).To(MatchError("cannot set Opts for key 'opts', confkey.InlineOptions fields are not supported"))
+		})
+
+		It("Should support embedded structs", func() {
+			ed := EmbeddedData{}
+
+			Expect(SetStructDefaults(&ed)).To(Succeed())
+			Expect(ed.CA).To(Equal("/etc/ca.pem"))
+
+			Expect(SetStructFieldWithKey(&ed, "verify", "true")).To(Succeed())
+			Expect(ed.Verify).To(BeTrue())
+			Expect(BoolWithKey(&ed, "verify")).To(BeTrue())
+
+			Expect(SetStructFieldWithKey(&ed, "name", "outer")).To(Succeed())
+			Expect(ed.Name).To(Equal("outer"))
+			Expect(ed.EmbeddedTLS.Name).To(BeEmpty())
+
+			err := SetStructFieldWithKey(&ed, "tls_name", "inner")
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+
+			keys, err := Keys(&ed)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keys).To(ContainElement("ca"))
+		})
+
+		It("Should support nested structs using dotted keys", func() {
+			n := NestedData{}
+
+			Expect(SetStructFieldWithKey(&n, "tls.ca", "/ca.pem")).To(Succeed())
+			Expect(n.TLS.CA).To(Equal("/ca.pem"))
+			Expect(StringFieldWithKey(&n, "tls.ca")).To(Equal("/ca.pem"))
+
+			Expect(SetStructFieldWithKey(&n, "tls.mode", "other")).To(MatchError("Mode enum validation failed: 'other' is not in the allowed list: verify, none"))
+
+			err := SetStructFieldWithKey(&n, "tls.missing", "x")
+			Expect(err).To(MatchError("can't find any structure element configured with confkey 'tls.missing'"))
+
+			err = SetStructFieldWithKey(&n, "name.other", "x")
+			Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+
+			p := PrefixDefaultsData{}
+			Expect(SetStructFieldWithKey(&p, "tls.ca", "/other.pem")).To(Succeed())
+			Expect(p.CA).To(Equal("/other.pem"))
+		})
+
+		It("Should support inline structs", func() {
+			id := InlineData{}
+
+			err := SetStructFieldWithKey(&id, "opts", "retries=3, timeout=5s")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id.Opts.Retries).To(Equal(3))
+			Expect(id.Opts.Timeout).To(Equal(5 * time.Second))
+
+			err = SetStructFieldWithKey(&id, "opts", "retries=3,other=1")
+			Expect(err).To(MatchError("can't find any structure element configured with confkey 'other'"))
+
+			err = SetStructFieldWithKey(&id, "opts", "retries")
+			Expect(err).To(MatchError("invalid inline setting 'retries' for key 'opts', expected key=value"))
+		})
+
+		It("Should coerce loosely typed values", func() {
+			err := SetStructFieldWithKey(&d, "plain_string", 8080)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.PlainString).To(Equal("8080"))
+
+			err = SetStructFieldWithKey(&d, "plain_string", true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.PlainString).To(Equal("true"))
+
+			err = SetStructFieldWithKey(&d, "plain_string", 1.5)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.PlainString).To(Equal("1.5"))
+
+			err = SetStructFieldWithKey(&d, "int", float64(8080))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Int).To(Equal(8080))
+
+			err = SetStructFieldWithKey(&d, "int", int64(10))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Int).To(Equal(10))
+
+			err = SetStructFieldWithKey(&d, "int", 1.5)
+			Expect(err).To(HaveOccurred())
+
+			err = SetStructFieldWithKey(&d, "interval", 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.T).To(Equal(10 * time.Second))
+
+			err = SetStructFieldWithKey(&d, "bool", true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Bool).To(BeTrue())
+
+			err = SetStructFieldWithKey(&d, "comma_split", 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{"1"}))
+		})
+
+		It("Should reject values that are not scalars", func() {
+			Expect(SetStructFieldWithKey(&d, "plain_string", nil)).To(MatchError("invalid value for key 'plain_string': a value is required"))
+			Expect(SetStructFieldWithKey(&d, "int", map[string]int{})).To(MatchError("invalid value for key 'int': unsupported value of type map[string]int"))
+			Expect(SetStructFieldWithKey(&d, "interval", struct{}{})).To(MatchError("invalid value for key 'interval': unsupported value of type struct {}"))
+		})
+
+		It("Should support named scalar types", func() {
+			Expect(SetStructFieldWithKey(&d, "interval", 90*time.Second)).To(Succeed())
+			Expect(d.T).To(Equal(90 * time.Second))
+
+			Expect(SetStructFieldWithKey(&d, "int", LogLevel(3))).To(Succeed())
+			Expect(d.Int).To(Equal(3))
+		})
+
+		It("Should support named types", func() {
+			nt := NamedTypesData{}
+
+			Expect(SetStructFieldWithKey(&nt, "level", "DEBUG")).To(Succeed())
+			Expect(SetStructFieldWithKey(&nt, "count", "10")).To(Succeed())
+			Expect(SetStructFieldWithKey(&nt, "enabled", "yes")).To(Succeed())
+			Expect(SetStructFieldWithKey(&nt, "timeout", "1m")).To(Succeed())
+			Expect(nt).To(Equal(NamedTypesData{Level: "debug", Count: 10, Enabled: true, Timeout: NamedTimeout(time.Minute)}))
+
+			Expect(StringFieldWithKey(&nt, "level")).To(Equal("debug"))
+			Expect(IntWithKey(&nt, "count")).To(Equal(10))
+			Expect(BoolWithKey(&nt, "enabled")).To(BeTrue())
+			Expect(DurationWithKey(&nt, "timeout")).To(Equal(time.Minute))
+		})
+
+		It("Should support lists", func() {
+			d.CommaSplit = []string{"old"}
+
+			err := SetStructFieldWithKey(&d, "comma_split", []string{" a ", "b,c"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{"a", "b,c"}))
+
+			err = SetStructFieldWithKey(&d, "comma_split", []interface{}{"x", 1, true})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{"x", "1", "true"}))
+
+			il := IntListData{}
+			err = SetStructFieldWithKey(&il, "ports", []string{"80", "443"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(il.Ports).To(Equal([]int{80, 443}))
+
+			err = SetStructFieldWithKey(&d, "plain_string", []string{"a"})
+			Expect(err).To(MatchError("cannot set a list for key 'plain_string', string fields require a single value"))
+
+			err = SetStructFieldWithKey(&d, "comma_split", []interface{}{"a", map[string]string{}})
+			Expect(err).To(MatchError("invalid value for key 'comma_split': unsupported list item map[]"))
+		})
+
+		It("Should support aliases", func() {
+			ad := AliasData{}
+
+			err := SetStructFieldWithKey(&ad, "feature", "yes")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ad.Feature).To(BeTrue())
+
+			err = SetStructFieldWithKey(&ad, "no_feature", "true")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ad.Feature).To(BeFalse())
+
+			err = SetStructFieldWithKey(&ad, "no_feature", "false")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ad.Feature).To(BeTrue())
+
+			err = SetStructFieldWithKey(&ad, "enable_feature", "false")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ad.Feature).To(BeFalse())
+
+			err = SetStructFieldWithKey(&ad, "title", "hello")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ad.Name).To(Equal("hello"))
+
+			err = SetStructFieldWithKey(&ad, "untitled", "true")
+			Expect(err).To(MatchError("negated alias 'untitled' can only be used on bool fields"))
+
+			os.Setenv("ALIAS_FEATURE", "true")
+			defer os.Unsetenv("ALIAS_FEATURE")
+			err = SetStructFieldWithKey(&ad, "no_feature", "true")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ad.Feature).To(BeTrue())
+		})
+
+		It("Should support escaped separators", func() {
+			err := SetStructFieldWithKey(&d, "escaped", `a\,b, c`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Escaped).To(Equal([]string{"a,b", "c"}))
+
+			err = SetStructFieldWithKey(&d, "escaped", `a\\, b`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Escaped).To(Equal([]string{`a\`, "b"}))
+
+			err = SetStructFieldWithKey(&d, "escaped", `a,b\`)
+			Expect(err).To(MatchError("invalid value for key 'escaped': trailing backslash"))
+
+			err = SetStructFieldWithKey(&d, "comma_split", `a\,b`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.CommaSplit).To(Equal([]string{`a\`, "b"}))
+		})
+
+		It("Should support max_total_len", func() {
+			err := SetStructFieldWithKey(&d, "bounded", "abcd, efgh")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Bounded).To(Equal([]string{"abcd", "efgh"}))
+
+			err = SetStructFieldWithKey(&d, "bounded", "abcd, efgh, ijk")
+			Expect(err).To(MatchError("bounded exceeds the maximum total length of 10 with 11"))
+			Expect(d.Bounded).To(Equal([]string{"abcd", "efgh"}))
+		})
+
+		It("Should support len", func() {
+			err := SetStructFieldWithKey(&d, "range", "1, 100")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Range).To(Equal([]string{"1", "100"}))
+
+			err = SetStructFieldWithKey(&d, "range", "1,100,1000")
+			Expect(err).To(MatchError("range requires 2 items but 3 were given"))
+			Expect(d.Range).To(Equal([]string{"1", "100"}))
+		})
+
+		It("Should support positive and non_negative", func() {
+			Expect(SetStructFieldWithKey(&d, "count", "1")).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&d, "count", "0")).To(MatchError("count must be greater than 0"))
+			Expect(d.Count).To(Equal(1))
+
+			Expect(SetStructFieldWithKey(&d, "offset", "0")).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&d, "offset", "-1")).To(MatchError("offset may not be negative"))
+
+			Expect(SetStructFieldWithKey(&d, "timeout", "1s")).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&d, "timeout", "0")).To(MatchError("timeout must be greater than 0"))
+			Expect(d.Timeout).To(Equal(time.Second))
+		})
+
+		It("Should support strip_suffix", func() {
+			Expect(SetStructFieldWithKey(&d, "seconds", "30s")).ToNot(HaveOccurred())
+			Expect(d.Seconds).To(Equal(30))
+
+			Expect(SetStructFieldWithKey(&d, "seconds", "40")).ToNot(HaveOccurred())
+			Expect(d.Seconds).To(Equal(40))
+
+			Expect(SetStructFieldWithKey(&d, "int", "30s")).To(HaveOccurred())
+		})
+
+		It("Should support trim_prefix and trim_suffix", func() {
+			Expect(SetStructFieldWithKey(&d, "base_url", "https://example.net/")).ToNot(HaveOccurred())
+			Expect(d.BaseURL).To(Equal("https://example.net"))
+
+			Expect(SetStructFieldWithKey(&d, "base_url", "https://example.net")).ToNot(HaveOccurred())
+			Expect(d.BaseURL).To(Equal("https://example.net"))
+
+			Expect(SetStructFieldWithKey(&d, "host", "https://example.net/")).ToNot(HaveOccurred())
+			Expect(d.Host).To(Equal("example.net"))
+		})
+
+		It("Should support match", func() {
+			md := MatchData{}
+
+			Expect(SetStructFieldWithKey(&md, "name", "web-1")).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&md, "name", "Web-1")).To(MatchError("name value 'Web-1' does not match ^[a-z][a-z0-9-]*$"))
+			Expect(md.Name).To(Equal("web-1"))
+
+			Expect(SetStructFieldWithKey(&md, "bad", "x")).To(MatchError("invalid match pattern for key 'bad': error parsing regexp: missing closing ]: `[a-z`"))
+			Expect(Validate(MatchData{Name: "x"})).To(MatchError("invalid match pattern for key 'bad': error parsing regexp: missing closing ]: `[a-z`"))
+		})
+
+		It("Should support unsigned ints", func() {
+			Expect(SetStructFieldWithKey(&d, "uint", "10")).ToNot(HaveOccurred())
+			Expect(d.Uint).To(Equal(uint(10)))
+
+			Expect(SetStructFieldWithKey(&d, "uint64", "18446744073709551615")).ToNot(HaveOccurred())
+			Expect(d.Uint64).To(Equal(uint64(18446744073709551615)))
+
+			Expect(SetStructFieldWithKey(&d, "uint", "-1")).To(MatchError(`strconv.ParseUint: parsing "-1": invalid syntax`))
+			Expect(SetStructFieldWithKey(&d, "uint64", "18446744073709551616")).To(MatchError(`strconv.ParseUint: parsing "18446744073709551616": value out of range`))
+		})
+
+		It("Should support floats", func() {
+			Expect(SetStructFieldWithKey(&d, "ratio", "0.5")).ToNot(HaveOccurred())
+			Expect(d.Ratio).To(Equal(0.5))
+
+			Expect(SetStructFieldWithKey(&d, "ratio", "1.5e3")).ToNot(HaveOccurred())
+			Expect(d.Ratio).To(Equal(1500.0))
+
+			Expect(SetStructFieldWithKey(&d, "ratio32", "0.25")).ToNot(HaveOccurred())
+			Expect(d.Ratio32).To(Equal(float32(0.25)))
+
+			Expect(SetStructFieldWithKey(&d, "ratio32", "1e40")).To(MatchError(`strconv.ParseFloat: parsing "1e40": value out of range`))
+			Expect(SetStructFieldWithKey(&d, "ratio", "half")).To(MatchError(`strconv.ParseFloat: parsing "half": invalid syntax`))
+		})
+
+		It("Should support float defaults and environment", func() {
+			Expect(SetStructDefaults(&d)).ToNot(HaveOccurred())
+			Expect(d.Ratio).To(Equal(0.75))
+
+			os.Setenv("TEST_RATIO", "0.1")
+			defer os.Unsetenv("TEST_RATIO")
+
+			Expect(SetStructFieldWithKey(&d, "ratio", "0.5")).ToNot(HaveOccurred())
+			Expect(d.Ratio).To(Equal(0.1))
+		})
+
+		It("Should support ints", func() {
+			err := SetStructFieldWithKey(&d, "int", "1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Int).To(Equal(1))
+
+			for v, expected := range map[string]int{"0x1F": 31, "0755": 493, "0o17": 15, "0b101": 5, "-12": -12, " 10": 10} {
+				err = SetStructFieldWithKey(&d, "int", v)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d.Int).To(Equal(expected))
+			}
+		})
+
+		It("Should support int64s", func() {
+			err := SetStructFieldWithKey(&d, "int64", " 10 ")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Int64).To(Equal(int64(10)))
+
+			err = SetStructFieldWithKey(&d, "int64", "0xFF")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Int64).To(Equal(int64(255)))
+
+			err = SetStructFieldWithKey(&d, "int64", "ten")
+			Expect(err).To(MatchError(`strconv.ParseInt: parsing "ten": invalid syntax`))
+		})
+
+		It("Should support title_string", func() {
+			err := SetStructFieldWithKey(&d, "title_string", "foobar")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.TitleString).To(Equal("Foobar"))
+
+			err = SetStructFieldWithKey(&d, "title_string", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.TitleString).To(Equal(""))
+		})
+
+		It("Should support upper_string and lower_string", func() {
+			err := SetStructFieldWithKey(&d, "upper_string", "tcp")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.UpperString).To(Equal("TCP"))
+
+			err = SetStructFieldWithKey(&d, "lower_string", "Node-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.LowerString).To(Equal("node-1"))
+
+			err = SetStructFieldWithKey(&d, "upper_string", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.UpperString).To(Equal(""))
+
+			err = SetStructFieldWithKey(&d, "lower_string", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.LowerString).To(Equal(""))
+		})
+
+		It("Should support path_string", func() {
+			err := os.Setenv("HOME", "/home/joeuser")
+			Expect(err).ToNot(HaveOccurred())
+			err = os.Setenv("HOMEDRIVE", "C:\\")
+			Expect(err).ToNot(HaveOccurred())
+			err = os.Setenv("HOMEDIR", "myhome")
 			Expect(err).ToNot(HaveOccurred())
 
 			if runtime.GOOS == "windows" {
@@ -188,6 +1838,65 @@ var _ = Describe("Confkey", func() {
 			}
 		})
 
+		It("Should support filepath", func() {
+			err := os.Setenv("HOME", "/home/joeuser")
+			Expect(err).ToNot(HaveOccurred())
+
+			if runtime.GOOS == "windows" {
+				Skip("filepath tests are unix only")
+			}
+
+			fp := FilePathData{}
+			Expect(SetStructFieldWithKey(&fp, "keydir", " ~/.app/keys ")).To(Succeed())
+			Expect(fp.KeyDir).To(Equal("/home/joeuser/.app/keys"))
+
+			Expect(SetStructFieldWithKey(&fp, "keydir", "~")).To(Succeed())
+			Expect(fp.KeyDir).To(Equal("/home/joeuser"))
+
+			Expect(SetStructFieldWithKey(&fp, "keydir", "/etc/app/~keys")).To(Succeed())
+			Expect(fp.KeyDir).To(Equal("/etc/app/~keys"))
+
+			u, err := user.Current()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&fp, "keydir", "~"+u.Username+"/keys")).To(Succeed())
+			Expect(fp.KeyDir).To(Equal(u.HomeDir + "/keys"))
+
+			Expect(SetStructFieldWithKey(&fp, "keydir", "~nosuchuser.confkey/keys")).To(MatchError(ContainSubstring("cannot expand ~nosuchuser.confkey/keys")))
+
+			Expect(SetStructFieldWithKey(&fp, "dirs", "~/a,b")).To(Succeed())
+			Expect(fp.Dirs).To(Equal([]string{"/home/joeuser/a,b"}))
+
+			Expect(SetStructFieldWithKey(&fp, "plugins", "~/a, /b, ~/c")).To(Succeed())
+			Expect(fp.Plugins).To(Equal([]string{"/home/joeuser/a", "/b", "/home/joeuser/c"}))
+		})
+
+		It("Should support url", func() {
+			ud := URLData{}
+
+			Expect(SetStructFieldWithKey(&ud, "endpoint", " https://example.net/api ")).To(Succeed())
+			Expect(ud.Endpoint).To(Equal("https://example.net/api"))
+
+			Expect(SetStructFieldWithKey(&ud, "endpoint", "http://[::1")).To(MatchError(`invalid url for key 'endpoint': parse "http://[::1": missing ']' in host`))
+			Expect(ud.Endpoint).To(Equal("https://example.net/api"))
+
+			Expect(SetStructFieldWithKey(&ud, "api", "https://example.net:8443/v1")).To(Succeed())
+			Expect(ud.API.Host).To(Equal("example.net:8443"))
+			Expect(ud.API.Path).To(Equal("/v1"))
+
+			Expect(SetStructFieldWithKey(&ud, "api", "http://[::1")).To(HaveOccurred())
+			Expect(ud.API.Host).To(Equal("example.net:8443"))
+
+			Expect(SetStructFieldWithKey(&ud, "base", "nats://localhost:4222")).To(Succeed())
+			Expect(ud.Base.Scheme).To(Equal("nats"))
+
+			Expect(SetStructFieldWithKey(&ud, "other", "x")).To(MatchError("type url is only supported on string and url.URL fields, other is time.Time"))
+
+			m, err := MarshalMap(&ud)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m["api"]).To(Equal("https://example.net:8443/v1"))
+			Expect(m["base"]).To(Equal("nats://localhost:4222"))
+		})
+
 		It("Should support bools", func() {
 			for _, v := range []string{"1", "YES", "y", "tRue", "t"} {
 				err := SetStructFieldWithKey(&d, "bool", v)
@@ -195,13 +1904,78 @@ var _ = Describe("Confkey", func() {
 				Expect(d.Bool).To(Equal(true))
 			}
 
-			for _, v := range []string{"0", "NO", "f", "FalSE", "n", "invalid"} {
+			for _, v := range []string{"0", "NO", "f", "FalSE", "n"} {
 				err := SetStructFieldWithKey(&d, "bool", v)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d.Bool).To(Equal(false))
 			}
 		})
 
+		It("Should support flag style bools", func() {
+			err := SetStructFieldWithKey(&d, "flag", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Flag).To(BeTrue())
+
+			err = SetStructFieldWithKey(&d, "flag", "no")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Flag).To(BeFalse())
+
+			err = SetStructFieldWithKey(&d, "bool", "")
+			Expect(err).To(MatchError("cannot convert string value '' into a boolean."))
+		})
+
+		It("Should fail for invalid bools", func() {
+			d.Bool = true
+
+			err := SetStructFieldWithKey(&d, "bool", "maybe")
+			Expect(err).To(MatchError("cannot convert string value 'maybe' into a boolean."))
+			Expect(d.Bool).To(BeTrue())
+		})
+
+		It("Should support nonzero numeric bools", func() {
+			for _, v := range []string{"1", "2", "-1", "yes"} {
+				err := SetStructFieldWithKey(&d, "numeric_bool", v)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d.NumericBool).To(BeTrue())
+			}
+
+			for _, v := range []string{"0", "no"} {
+				err := SetStructFieldWithKey(&d, "numeric_bool", v)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d.NumericBool).To(BeFalse())
+			}
+
+			err := SetStructFieldWithKey(&d, "bool", "2")
+			Expect(err).To(MatchError("cannot convert string value '2' into a boolean."))
+			Expect(d.Bool).To(BeFalse())
+		})
+
+		It("Should support byte sizes", func() {
+			bd := BytesData{}
+
+			Expect(SetStructFieldWithKey(&bd, "max_body", "10MB")).To(Succeed())
+			Expect(bd.MaxBody).To(Equal(10 * 1000 * 1000))
+
+			Expect(SetStructFieldWithKey(&bd, "max_body", "512")).To(Succeed())
+			Expect(bd.MaxBody).To(Equal(512))
+
+			Expect(SetStructFieldWithKey(&bd, "cache", "2GiB")).To(Succeed())
+			Expect(bd.Cache).To(Equal(int64(2 * 1024 * 1024 * 1024)))
+
+			Expect(SetStructFieldWithKey(&bd, "cache", "1.5 kib")).To(Succeed())
+			Expect(bd.Cache).To(Equal(int64(1536)))
+
+			Expect(SetStructFieldWithKey(&bd, "cache", "10XB")).To(MatchError("invalid size '10XB', unknown suffix 'XB'"))
+			Expect(SetStructFieldWithKey(&bd, "cache", "lots")).To(MatchError("invalid size 'lots'"))
+			Expect(SetStructFieldWithKey(&bd, "cache", "0")).To(MatchError("cache must be greater than 0"))
+			Expect(bd.Cache).To(Equal(int64(1536)))
+
+			Expect(SetStructFieldWithKey(&bd, "cache", "9223372036854775808")).To(MatchError("invalid size '9223372036854775808', value out of range"))
+			Expect(SetStructFieldWithKey(&bd, "cache", "100000000TB")).To(MatchError("invalid size '100000000TB', value out of range"))
+			Expect(SetStructFieldWithKey(&bd, "max_body", "100000000TB")).To(MatchError("invalid size '100000000TB', value out of range"))
+			Expect(bd.Cache).To(Equal(int64(1536)))
+		})
+
 		It("Should support durations", func() {
 			err := SetStructFieldWithKey(&d, "interval", "1s")
 			Expect(err).ToNot(HaveOccurred())
@@ -215,5 +1989,56 @@ var _ = Describe("Confkey", func() {
 			Expect(err).ToNot(HaveOccurred())
 			Expect(d.T).To(Equal(1 * time.Hour))
 		})
+
+		It("Should support negative durations", func() {
+			err := SetStructFieldWithKey(&d, "interval", "-30m")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.T).To(Equal(-30 * time.Minute))
+
+			m, err := MarshalMap(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m["interval"]).To(Equal("-30m0s"))
+
+			n := TestData{}
+			Expect(SetStructFieldWithKey(&n, "interval", m["interval"])).To(Succeed())
+			Expect(n.T).To(Equal(d.T))
+
+			err = SetStructFieldWithKey(&d, "interval", "-45")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.T).To(Equal(-45 * time.Second))
+
+			err = SetStructFieldWithKey(&d, "interval", "-1d")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.T).To(Equal(-24 * time.Hour))
+		})
+
+		It("Should support day and week durations", func() {
+			err := SetStructFieldWithKey(&d, "interval", "7d")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.T).To(Equal(7 * 24 * time.Hour))
+
+			err = SetStructFieldWithKey(&d, "interval", "2w")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.T).To(Equal(14 * 24 * time.Hour))
+
+			err = SetStructFieldWithKey(&d, "interval", "1.5d")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should support times", func() {
+			td := TimeData{}
+
+			err := SetStructFieldWithKey(&td, "start", "2020-01-02T15:04:05Z")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(td.Start).To(Equal(time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)))
+
+			err = SetStructFieldWithKey(&td, "day", "2020-03-04")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(td.Day).To(Equal(time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)))
+
+			err = SetStructFieldWithKey(&td, "day", "04/03/2020")
+			Expect(err).To(HaveOccurred())
+			Expect(td.Day).To(Equal(time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)))
+		})
 	})
 })
@@ -0,0 +1,55 @@
+package confkey
+
+import (
+	"reflect"
+)
+
+// KeyInfo describes a single confkey, see DescribeKeys
+type KeyInfo struct {
+	// Key is the confkey, keys of nested structs are dotted like tls.ca
+	Key string
+
+	// Type is the Go type of the field
+	Type string
+
+	// Default is the value of the default tag
+	Default string
+
+	// Environment is the environment variable that overrides the key
+	Environment string
+
+	// Validate is the validation rule set using the validate tag
+	Validate string
+
+	// Description is the value of the description tag
+	Description string
+}
+
+// DescribeKeys retrieves the metadata of every confkey on target in the order they are
+// declared, this is useful to generate documentation for a configuration structure
+func DescribeKeys(target interface{}) ([]KeyInfo, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []KeyInfo{}
+
+	err = walkFields(val, "", func(key string, field reflect.StructField, _ reflect.Value) error {
+		keys = append(keys, KeyInfo{
+			Key:         key,
+			Type:        field.Type.String(),
+			Default:     field.Tag.Get("default"),
+			Environment: field.Tag.Get("environment"),
+			Validate:    field.Tag.Get("validate"),
+			Description: field.Tag.Get("description"),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
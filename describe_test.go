@@ -0,0 +1,34 @@
+package confkey
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type DescribeTLS struct {
+	CA string `confkey:"ca" description:"Path to the CA"`
+}
+
+type DescribeData struct {
+	Loglevel string      `confkey:"loglevel" default:"warn" validate:"enum=debug,info,warn" description:"The level to log at"`
+	Servers  []string    `confkey:"servers" type:"comma_split" environment:"DESCRIBE_SERVERS"`
+	TLS      DescribeTLS `confkey:"tls"`
+	Internal string
+}
+
+var _ = Describe("DescribeKeys", func() {
+	It("Should describe every key", func() {
+		keys, err := DescribeKeys(&DescribeData{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(Equal([]KeyInfo{
+			{Key: "loglevel", Type: "string", Default: "warn", Validate: "enum=debug,info,warn", Description: "The level to log at"},
+			{Key: "servers", Type: "[]string", Environment: "DESCRIBE_SERVERS"},
+			{Key: "tls.ca", Type: "string", Description: "Path to the CA"},
+		}))
+	})
+
+	It("Should require a struct", func() {
+		_, err := DescribeKeys("x")
+		Expect(err).To(MatchError("struct is required"))
+	})
+})
@@ -0,0 +1,205 @@
+package confkey
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ToEnviron renders every field with an environment tag as NAME=value strings suitable
+// for use in exec.Cmd.Env, WithEnvPrefix can be used to include untagged fields
+//
+// Fields holding secrets are included as-is since the child process needs them, take
+// care to not log or otherwise expose the result
+func ToEnviron(target interface{}, opts ...Option) ([]string, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	env := []string{}
+
+	err = walkEnvFields(val, newOptions(opts...), func(_ string, name string, field reflect.StructField, v reflect.Value) error {
+		s, err := marshalValue(v, field)
+		if err != nil {
+			return err
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", name, s))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// walkEnvFields calls fn for every field of val, including those of nested structs, that has
+// an environment variable name. Keys seen before belong to an earlier field and are skipped
+func walkEnvFields(val reflect.Value, o *options, fn func(key string, name string, field reflect.StructField, v reflect.Value) error) error {
+	seen := make(map[string]struct{})
+
+	return walkFields(val, "", func(key string, field reflect.StructField, v reflect.Value) error {
+		if _, ok := seen[key]; ok {
+			return nil
+		}
+		seen[key] = struct{}{}
+
+		name := o.envName(field, key)
+		if name == "" {
+			return nil
+		}
+
+		return fn(key, name, field, v)
+	})
+}
+
+// EnvVars maps the environment variable of every field with an environment tag to the
+// current value of the field, fields still holding their zero value report their default
+// or an empty string. The values of secret fields that are set are replaced with Redacted
+func EnvVars(target interface{}) (map[string]string, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+
+	// only the environment tag is considered, no prefix is set
+	err = walkEnvFields(val, newOptions(), func(_ string, env string, field reflect.StructField, fv reflect.Value) error {
+		switch {
+		case isZero(fv):
+			vars[env] = field.Tag.Get("default")
+
+		case field.Tag.Get("secret") == "true":
+			vars[env] = Redacted
+
+		default:
+			v, err := marshalValue(fv, field)
+			if err != nil {
+				return err
+			}
+
+			vars[env] = v
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// FromEnviron applies the defaults to target and then sets every field whose environment
+// variable is found in os.Environ, either from its environment tag or a name derived using
+// WithEnvPrefix. Variables not matching any field are ignored unless WithStrict is used
+// together with WithEnvPrefix in which case unknown variables carrying the prefix are reported
+func FromEnviron(target interface{}, opts ...Option) error {
+	err := SetStructDefaults(target)
+	if err != nil {
+		return err
+	}
+
+	return loadEnviron(target, newOptions(opts...))
+}
+
+// LoadFromEnviron sets every field whose environment tag names a variable found in
+// os.Environ using the normal conversions, unlike FromEnviron no defaults are applied
+// so fields without a variable keep their current value
+func LoadFromEnviron(target interface{}) error {
+	if target == nil || reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	return loadEnviron(target, newOptions())
+}
+
+// loadEnviron sets the fields of target from their environment variables found in os.Environ
+func loadEnviron(target interface{}, o *options) error {
+	o.skipEnv = true
+
+	environ := make(map[string]string)
+	for _, e := range os.Environ() {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) == 2 {
+			environ[parts[0]] = parts[1]
+		}
+	}
+
+	known := make(map[string]struct{})
+
+	err := walkEnvFields(reflect.ValueOf(target).Elem(), o, func(key string, name string, _ reflect.StructField, _ reflect.Value) error {
+		known[name] = struct{}{}
+
+		v, ok := environ[name]
+		if !ok {
+			return nil
+		}
+
+		err := setStructField(target, key, v, o)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if o.strict && o.envPrefix != "" {
+		unknown := []string{}
+
+		for name := range environ {
+			if _, ok := known[name]; !ok && strings.HasPrefix(name, o.envPrefix) {
+				unknown = append(unknown, name)
+			}
+		}
+
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("unknown environment variables: %s", strings.Join(unknown, ", "))
+		}
+	}
+
+	return nil
+}
+
+// RequireEnv ensures that the environment variable of every field tagged both
+// environment and required:"true" is set, regardless of file or default values
+func RequireEnv(target interface{}) error {
+	val, err := structValue(target)
+	if err != nil {
+		return err
+	}
+
+	missing := []string{}
+
+	err = walkEnvFields(val, newOptions(), func(_ string, env string, field reflect.StructField, _ reflect.Value) error {
+		if field.Tag.Get("required") != "true" {
+			return nil
+		}
+
+		if _, ok := os.LookupEnv(env); !ok {
+			missing = append(missing, env)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("required environment variables are not set: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,244 @@
+package confkey
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type EnvironData struct {
+	Servers  []string      `confkey:"servers" type:"comma_split" environment:"ENVIRON_SERVERS"`
+	Interval time.Duration `confkey:"interval" type:"duration" environment:"ENVIRON_INTERVAL"`
+	Debug    bool          `confkey:"debug" environment:"ENVIRON_DEBUG"`
+	Token    string        `confkey:"api.token"`
+	Internal string
+}
+
+type FromEnvironData struct {
+	Loglevel string        `confkey:"loglevel" default:"warn" environment:"FROMENV_LOGLEVEL"`
+	Servers  []string      `confkey:"servers" type:"comma_split" environment:"FROMENV_SERVERS"`
+	Interval time.Duration `confkey:"interval" type:"duration" default:"1m"`
+	Port     int           `confkey:"port" default:"8080"`
+	Debug    bool          `confkey:"debug"`
+}
+
+type NestedEnvData struct {
+	Name string       `confkey:"name" environment:"NESTED_NAME"`
+	TLS  NestedEnvTLS `confkey:"tls"`
+}
+
+type NestedEnvTLS struct {
+	CA   string `confkey:"ca" environment:"NESTED_CA" required:"true"`
+	Cert string `confkey:"cert"`
+}
+
+type RequiredEnvData struct {
+	Token  string `confkey:"token" environment:"REQUIRED_TOKEN" required:"true"`
+	Host   string `confkey:"host" environment:"REQUIRED_HOST"`
+	Secret string `confkey:"secret" environment:"REQUIRED_SECRET" required:"true"`
+}
+
+type EnvVarsData struct {
+	Loglevel string   `confkey:"loglevel" default:"warn" environment:"ENVVARS_LOGLEVEL"`
+	Servers  []string `confkey:"servers" type:"comma_split" environment:"ENVVARS_SERVERS"`
+	Debug    bool     `confkey:"debug" environment:"ENVVARS_DEBUG"`
+	Token    string   `confkey:"token" environment:"ENVVARS_TOKEN" secret:"true"`
+	Password string   `confkey:"password" environment:"ENVVARS_PASSWORD" secret:"true"`
+	Port     int      `confkey:"port" default:"8080"`
+}
+
+var _ = Describe("Environ", func() {
+	var d EnvironData
+
+	BeforeEach(func() {
+		d = EnvironData{
+			Servers:  []string{"s1", "s2"},
+			Interval: time.Minute,
+			Debug:    true,
+			Token:    "secret",
+			Internal: "x",
+		}
+	})
+
+	var _ = Describe("ToEnviron", func() {
+		It("Should render tagged fields", func() {
+			env, err := ToEnviron(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env).To(Equal([]string{"ENVIRON_SERVERS=s1,s2", "ENVIRON_INTERVAL=1m0s", "ENVIRON_DEBUG=true"}))
+		})
+
+		It("Should support prefixes for untagged fields", func() {
+			env, err := ToEnviron(d, WithEnvPrefix("APP_"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env).To(Equal([]string{"ENVIRON_SERVERS=s1,s2", "ENVIRON_INTERVAL=1m0s", "ENVIRON_DEBUG=true", "APP_API_TOKEN=secret"}))
+		})
+
+		It("Should render nested struct fields", func() {
+			n := NestedEnvData{Name: "x", TLS: NestedEnvTLS{CA: "/ca.pem", Cert: "/cert.pem"}}
+
+			env, err := ToEnviron(&n)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env).To(Equal([]string{"NESTED_NAME=x", "NESTED_CA=/ca.pem"}))
+
+			env, err = ToEnviron(&n, WithEnvPrefix("APP_"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(env).To(Equal([]string{"NESTED_NAME=x", "NESTED_CA=/ca.pem", "APP_TLS_CERT=/cert.pem"}))
+		})
+
+		It("Should round trip", func() {
+			env, err := ToEnviron(&d)
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, e := range env {
+				parts := strings.SplitN(e, "=", 2)
+				os.Setenv(parts[0], parts[1])
+				defer os.Unsetenv(parts[0])
+			}
+
+			n := EnvironData{}
+			err = SetFields(&n, map[string]string{"servers": "", "interval": "1s", "debug": "false"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n.Servers).To(Equal(d.Servers))
+			Expect(n.Interval).To(Equal(d.Interval))
+			Expect(n.Debug).To(Equal(d.Debug))
+		})
+	})
+	var _ = Describe("EnvVars", func() {
+		It("Should report current values and defaults", func() {
+			vars, err := EnvVars(&EnvVarsData{Servers: []string{"a", "b"}, Token: "s3cret"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vars).To(Equal(map[string]string{
+				"ENVVARS_LOGLEVEL": "warn",
+				"ENVVARS_SERVERS":  "a,b",
+				"ENVVARS_DEBUG":    "",
+				"ENVVARS_TOKEN":    Redacted,
+				"ENVVARS_PASSWORD": "",
+			}))
+
+			vars, err = EnvVars(NestedEnvData{TLS: NestedEnvTLS{CA: "/ca.pem"}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(vars).To(Equal(map[string]string{"NESTED_NAME": "", "NESTED_CA": "/ca.pem"}))
+		})
+
+		It("Should require a struct", func() {
+			_, err := EnvVars(nil)
+			Expect(err).To(MatchError("target is required"))
+
+			_, err = EnvVars("x")
+			Expect(err).To(MatchError("struct is required"))
+		})
+	})
+
+	var _ = Describe("RequireEnv", func() {
+		It("Should fail for unset variables", func() {
+			r := RequiredEnvData{Token: "from file"}
+
+			os.Setenv("REQUIRED_SECRET", "s")
+			defer os.Unsetenv("REQUIRED_SECRET")
+
+			Expect(RequireEnv(&r)).To(MatchError("required environment variables are not set: REQUIRED_TOKEN"))
+
+			os.Setenv("REQUIRED_TOKEN", "t")
+			defer os.Unsetenv("REQUIRED_TOKEN")
+
+			Expect(RequireEnv(r)).ToNot(HaveOccurred())
+		})
+
+		It("Should check nested struct fields", func() {
+			Expect(RequireEnv(NestedEnvData{})).To(MatchError("required environment variables are not set: NESTED_CA"))
+
+			os.Setenv("NESTED_CA", "/ca.pem")
+			defer os.Unsetenv("NESTED_CA")
+
+			Expect(RequireEnv(NestedEnvData{})).ToNot(HaveOccurred())
+		})
+	})
+	var _ = Describe("FromEnviron", func() {
+		envs := map[string]string{
+			"FROMENV_LOGLEVEL": "debug",
+			"FROMENV_SERVERS":  "s1, s2",
+			"FROMENV_INTERVAL": "10s",
+			"FROMENV_DEBUG":    "yes",
+		}
+
+		BeforeEach(func() {
+			for k, v := range envs {
+				os.Setenv(k, v)
+			}
+		})
+
+		AfterEach(func() {
+			for k := range envs {
+				os.Unsetenv(k)
+			}
+			os.Unsetenv("FROMENV_UNKNOWN")
+		})
+
+		It("Should apply defaults and tagged variables", func() {
+			f := FromEnvironData{}
+			Expect(FromEnviron(&f)).ToNot(HaveOccurred())
+			Expect(f).To(Equal(FromEnvironData{Loglevel: "debug", Servers: []string{"s1", "s2"}, Interval: time.Minute, Port: 8080}))
+		})
+
+		It("Should support prefixes", func() {
+			f := FromEnvironData{}
+			Expect(FromEnviron(&f, WithEnvPrefix("FROMENV_"))).ToNot(HaveOccurred())
+			Expect(f).To(Equal(FromEnvironData{Loglevel: "debug", Servers: []string{"s1", "s2"}, Interval: 10 * time.Second, Port: 8080, Debug: true}))
+		})
+
+		It("Should report unknown variables in strict mode", func() {
+			os.Setenv("FROMENV_UNKNOWN", "1")
+
+			f := FromEnvironData{}
+			Expect(FromEnviron(&f, WithEnvPrefix("FROMENV_"))).ToNot(HaveOccurred())
+			Expect(FromEnviron(&f, WithEnvPrefix("FROMENV_"), WithStrict())).To(MatchError("unknown environment variables: FROMENV_UNKNOWN"))
+		})
+	})
+
+	var _ = Describe("LoadFromEnviron", func() {
+		BeforeEach(func() {
+			os.Setenv("FROMENV_LOGLEVEL", "debug")
+			os.Setenv("FROMENV_SERVERS", "s1, s2")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("FROMENV_LOGLEVEL")
+			os.Unsetenv("FROMENV_SERVERS")
+		})
+
+		It("Should set tagged variables without applying defaults", func() {
+			f := FromEnvironData{Port: 10}
+			Expect(LoadFromEnviron(&f)).ToNot(HaveOccurred())
+			Expect(f).To(Equal(FromEnvironData{Loglevel: "debug", Servers: []string{"s1", "s2"}, Port: 10}))
+		})
+
+		It("Should set nested struct fields", func() {
+			os.Setenv("NESTED_CA", "/ca.pem")
+			defer os.Unsetenv("NESTED_CA")
+
+			n := NestedEnvData{}
+			Expect(LoadFromEnviron(&n)).ToNot(HaveOccurred())
+			Expect(n).To(Equal(NestedEnvData{TLS: NestedEnvTLS{CA: "/ca.pem"}}))
+
+			os.Setenv("APP_TLS_CERT", "/cert.pem")
+			defer os.Unsetenv("APP_TLS_CERT")
+
+			n = NestedEnvData{}
+			Expect(FromEnviron(&n, WithEnvPrefix("APP_"))).ToNot(HaveOccurred())
+			Expect(n).To(Equal(NestedEnvData{TLS: NestedEnvTLS{CA: "/ca.pem", Cert: "/cert.pem"}}))
+		})
+
+		It("Should require a pointer", func() {
+			Expect(LoadFromEnviron(FromEnvironData{})).To(MatchError("pointer is required"))
+			Expect(LoadFromEnviron(nil)).To(MatchError("pointer is required"))
+		})
+
+		It("Should report conversion failures", func() {
+			os.Setenv("FROMENV_SERVERS", `"s1`)
+			Expect(LoadFromEnviron(&FromEnvironData{})).To(MatchError(`FROMENV_SERVERS: invalid value for key 'servers': unterminated quote in "s1`))
+		})
+	})
+})
@@ -0,0 +1,45 @@
+package confkey
+
+import (
+	"sync"
+)
+
+// Guarded serializes access to a single config instance, the package functions are safe
+// to use concurrently on different targets but not on the same one, when a config is
+// changed while it is being read, like by an admin endpoint, access it through Guarded
+//
+// Target has to be a pointer to a struct and should not be accessed directly
+type Guarded struct {
+	Target interface{}
+
+	mu sync.RWMutex
+}
+
+// Set sets key on Target using SetStructFieldWithKey
+func (g *Guarded) Set(key string, value interface{}) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return SetStructFieldWithKey(g.Target, key, value)
+}
+
+// Get retrieves a copy of the value of the field on Target that matches key
+func (g *Guarded) Get(key string) (interface{}, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	field, _, err := getFieldValAndKind(g.Target, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cloneValue(field).Interface(), nil
+}
+
+// Do calls fn with Target while holding the lock, use this to read or change several fields at once
+func (g *Guarded) Do(fn func(target interface{}) error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return fn(g.Target)
+}
@@ -0,0 +1,70 @@
+package confkey
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Guarded", func() {
+	var g *Guarded
+
+	BeforeEach(func() {
+		g = &Guarded{Target: &TestData{StringEnum: "warn"}}
+	})
+
+	It("Should set and get values", func() {
+		Expect(g.Set("int", "10")).To(Succeed())
+
+		v, err := g.Get("int")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v).To(Equal(10))
+
+		_, err = g.Get("missing")
+		Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+	})
+
+	It("Should return copies", func() {
+		Expect(g.Set("comma_split", "a,b")).To(Succeed())
+
+		v, err := g.Get("comma_split")
+		Expect(err).ToNot(HaveOccurred())
+		v.([]string)[0] = "x"
+
+		Expect(g.Target.(*TestData).CommaSplit).To(Equal([]string{"a", "b"}))
+	})
+
+	It("Should serialize access", func() {
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < 20; i++ {
+			wg.Add(2)
+
+			go func(i int) {
+				defer wg.Done()
+				defer GinkgoRecover()
+
+				Expect(g.Set("comma_split", fmt.Sprintf("a,%d", i))).To(Succeed())
+			}(i)
+
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+
+				_, err := g.Get("comma_split")
+				Expect(err).ToNot(HaveOccurred())
+			}()
+		}
+
+		wg.Wait()
+
+		err := g.Do(func(target interface{}) error {
+			Expect(target.(*TestData).CommaSplit).To(HaveLen(2))
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
@@ -0,0 +1,131 @@
+package confkey
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalJSON sets the fields of target from a JSON object using the confkey tags rather
+// than json ones, every value is set using SetStructFieldWithKey so the usual conversions
+// and validation apply. Arrays set slices one item at a time, objects set nested structs
+// using dotted keys while map fields and structs tagged type:"inline" get k=v pairs
+func UnmarshalJSON(data []byte, target interface{}) error {
+	if reflect.TypeOf(target).Kind() != reflect.Ptr {
+		return errors.New("pointer is required")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	values := make(map[string]interface{})
+
+	err := dec.Decode(&values)
+	if err != nil {
+		return err
+	}
+
+	return setJSONValues(target, "", values)
+}
+
+func setJSONValues(target interface{}, prefix string, values map[string]interface{}) error {
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := prefix + k
+
+		err := setJSONValue(target, key, values[k])
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func setJSONValue(target interface{}, key string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, kind, err := getFieldValAndKind(target, key); err == nil && (kind == reflect.Map || isInlineKey(target, key)) {
+			pairs := []string{}
+
+			for mk, mv := range v {
+				s, err := jsonScalar(mv)
+				if err != nil {
+					return err
+				}
+
+				pairs = append(pairs, fmt.Sprintf("%s=%s", mk, s))
+			}
+			sort.Strings(pairs)
+
+			return SetStructFieldWithKey(target, key, strings.Join(pairs, ","))
+		}
+
+		return setJSONValues(target, key+".", v)
+
+	case []interface{}:
+		list := []string{}
+
+		for _, item := range v {
+			s, err := jsonScalar(item)
+			if err != nil {
+				return err
+			}
+
+			list = append(list, s)
+		}
+
+		return SetStructFieldWithKey(target, key, list)
+	}
+
+	s, err := jsonScalar(value)
+	if err != nil {
+		return err
+	}
+
+	return SetStructFieldWithKey(target, key, s)
+}
+
+// isInlineKey is true when key refers to a struct field tagged type:"inline", possibly within
+// a nested struct, these are set from k=v pairs rather than dotted keys
+func isInlineKey(target interface{}, key string) bool {
+	item, err := fieldWithKey(target, key)
+	if errors.Is(err, ErrUnknownKey) {
+		if sub, rest, ok := nestedTarget(target, key); ok {
+			return isInlineKey(sub, rest)
+		}
+	}
+	if err != nil {
+		return false
+	}
+
+	t, _ := tag(target, item, "type")
+
+	return t == "inline"
+}
+
+// jsonScalar converts a decoded JSON string, number, bool or null to its string form
+func jsonScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	return "", fmt.Errorf("unsupported value %v", value)
+}
@@ -0,0 +1,89 @@
+package confkey
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type JSONData struct {
+	Loglevel string            `confkey:"loglevel" validate:"enum=debug,info"`
+	Servers  []string          `confkey:"servers" type:"comma_split"`
+	Ports    []int             `confkey:"ports"`
+	Port     int               `confkey:"port"`
+	Big      uint64            `confkey:"big"`
+	Debug    bool              `confkey:"debug"`
+	Interval time.Duration     `confkey:"interval" type:"duration"`
+	Labels   map[string]string `confkey:"labels" type:"kv_split"`
+	TLS      NestedTLS         `confkey:"tls"`
+}
+
+var _ = Describe("UnmarshalJSON", func() {
+	It("Should set fields using their confkeys", func() {
+		jd := JSONData{}
+
+		err := UnmarshalJSON([]byte(`{
+			"loglevel": "info",
+			"servers": ["a", "b"],
+			"ports": [80, 443],
+			"port": 8080,
+			"big": 18446744073709551615,
+			"debug": true,
+			"interval": "1m",
+			"labels": {"b": "2", "a": 1},
+			"tls": {"ca": "/ca.pem", "mode": "none"}
+		}`), &jd)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jd).To(Equal(JSONData{
+			Loglevel: "info",
+			Servers:  []string{"a", "b"},
+			Ports:    []int{80, 443},
+			Port:     8080,
+			Big:      18446744073709551615,
+			Debug:    true,
+			Interval: time.Minute,
+			Labels:   map[string]string{"a": "1", "b": "2"},
+			TLS:      NestedTLS{CA: "/ca.pem", Mode: "none"},
+		}))
+	})
+
+	It("Should set arrays as a whole", func() {
+		jd := JSONData{Servers: []string{"old"}, Ports: []int{1}}
+
+		err := UnmarshalJSON([]byte(`{"servers": ["a,b", "c"], "ports": []}`), &jd)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jd.Servers).To(Equal([]string{"a,b", "c"}))
+		Expect(jd.Ports).To(BeEmpty())
+	})
+
+	It("Should set inline structs from objects", func() {
+		id := InlineData{}
+
+		err := UnmarshalJSON([]byte(`{"opts": {"retries": 3, "timeout": "5s"}}`), &id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(id.Opts.Retries).To(Equal(3))
+		Expect(id.Opts.Timeout).To(Equal(5 * time.Second))
+	})
+
+	It("Should report failures with the key", func() {
+		jd := JSONData{}
+
+		err := UnmarshalJSON([]byte(`{"loglevel": "warn"}`), &jd)
+		Expect(err).To(MatchError("loglevel: Loglevel enum validation failed: 'warn' is not in the allowed list: debug, info"))
+
+		err = UnmarshalJSON([]byte(`{"missing": 1}`), &jd)
+		Expect(errors.Is(err, ErrUnknownKey)).To(BeTrue())
+
+		err = UnmarshalJSON([]byte(`{"ports": [[1]]}`), &jd)
+		Expect(err).To(MatchError("ports: unsupported value [1]"))
+
+		err = UnmarshalJSON([]byte(`[1]`), &jd)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should require a pointer", func() {
+		Expect(UnmarshalJSON([]byte(`{}`), JSONData{})).To(MatchError("pointer is required"))
+	})
+})
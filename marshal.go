@@ -0,0 +1,468 @@
+package confkey
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// DriftRemoved is the value DriftReport reports for keys found only in the baseline
+const DriftRemoved = "<removed>"
+
+// Redacted replaces the value of fields tagged secret:"true" in rendered output
+const Redacted = "****"
+
+// DriftReport compares the current rendered value of every confkey on target with
+// the values in baseline and returns the keys that differ with their current values,
+// keys only present in the baseline are reported with the value DriftRemoved.
+// Values are rendered like MarshalMap so secrets are compared in their redacted form
+func DriftReport(target interface{}, baseline map[string]string) (map[string]string, error) {
+	current, err := MarshalMap(target)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := make(map[string]string)
+
+	for k, v := range current {
+		if bv, ok := baseline[k]; !ok || bv != v {
+			drift[k] = v
+		}
+	}
+
+	for k := range baseline {
+		if _, ok := current[k]; !ok {
+			drift[k] = DriftRemoved
+		}
+	}
+
+	return drift, nil
+}
+
+// Diff compares two instances of the same struct and returns every key whose rendered
+// value differs with its old and new value. Secrets are compared as is but reported
+// as Redacted
+func Diff(a, b interface{}) (map[string]struct{ Old, New string }, error) {
+	av, err := structValue(a)
+	if err != nil {
+		return nil, err
+	}
+
+	bv, err := structValue(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if av.Type() != bv.Type() {
+		return nil, fmt.Errorf("cannot compare %s with %s", av.Type(), bv.Type())
+	}
+
+	old, err := marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := marshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]struct{ Old, New string })
+	for k, v := range current {
+		if old[k] != v {
+			changed[k] = struct{ Old, New string }{old[k], v}
+		}
+	}
+
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	old, err = redact(a, old)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err = redact(b, current)
+	if err != nil {
+		return nil, err
+	}
+
+	for k := range changed {
+		changed[k] = struct{ Old, New string }{old[k], current[k]}
+	}
+
+	return changed, nil
+}
+
+// MarshalMap renders every confkey on target into the string form SetStructFieldWithKey
+// accepts, slices are joined using the separator implied by their type tag and the
+// values of secret fields are replaced with Redacted
+func MarshalMap(target interface{}) (map[string]string, error) {
+	m, err := marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	return redact(target, m)
+}
+
+// String renders target as sorted key=value pairs suitable for logging, the values
+// of secret fields are replaced with Redacted
+func String(target interface{}) string {
+	m, err := MarshalMap(target)
+	if err != nil {
+		return fmt.Sprintf("invalid config: %s", err)
+	}
+
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, m[k]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// redact replaces the values in rendered of keys belonging to secret fields of target
+func redact(target interface{}, rendered map[string]string) (map[string]string, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkFields(val, "", func(key string, field reflect.StructField, _ reflect.Value) error {
+		if _, ok := rendered[key]; ok && field.Tag.Get("secret") == "true" {
+			rendered[key] = Redacted
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rendered, nil
+}
+
+// MarshalText renders target as a config file that ParseConfig can read, keys are
+// sorted and keys of nested structs are grouped under [section] headers named
+// after the parent confkey. Secret fields are included as is so the output can
+// be read back
+func MarshalText(target interface{}) ([]byte, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string][]string)
+
+	err = walkFields(val, "", func(key string, field reflect.StructField, v reflect.Value) error {
+		section := ""
+		name := key
+
+		if idx := strings.LastIndex(key, "."); idx > -1 {
+			section = key[:idx]
+			name = key[idx+1:]
+		}
+
+		// slices without a split type are set one item per line, as are line_split ones
+		if v.Kind() == reflect.Slice && (listSeparator(field) == "" || listSeparator(field) == "\n") {
+			for i := 0; i < v.Len(); i++ {
+				sections[section] = append(sections[section], fmt.Sprintf("%s = %v", name, v.Index(i).Interface()))
+			}
+
+			return nil
+		}
+
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+			return nil
+		}
+
+		// unset pointers are left out so they remain nil when read back
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+
+		rendered, err := marshalValue(v, field)
+		if err != nil {
+			return err
+		}
+
+		sections[section] = append(sections[section], fmt.Sprintf("%s = %s", name, rendered))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := strings.Builder{}
+
+	for _, name := range names {
+		if name != "" {
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+
+			out.WriteString(fmt.Sprintf("[%s]\n", name))
+		}
+
+		// stable so repeated keys keep their order
+		lines := sections[name]
+		sort.SliceStable(lines, func(i, j int) bool {
+			return lineKey(lines[i]) < lineKey(lines[j])
+		})
+
+		for _, line := range lines {
+			out.WriteString(line + "\n")
+		}
+	}
+
+	return []byte(out.String()), nil
+}
+
+func lineKey(line string) string {
+	return strings.SplitN(line, " = ", 2)[0]
+}
+
+// marshal renders every confkey on target into its string form
+func marshal(target interface{}) (map[string]string, error) {
+	val, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+
+	err = walkFields(val, "", func(key string, field reflect.StructField, v reflect.Value) error {
+		rendered, err := marshalValue(v, field)
+		if err != nil {
+			return err
+		}
+
+		result[key] = rendered
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// structValue resolves target to the struct it holds or points to
+func structValue(target interface{}) (reflect.Value, error) {
+	if target == nil {
+		return reflect.Value{}, errors.New("target is required")
+	}
+
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("struct is required")
+	}
+
+	return val, nil
+}
+
+// walkFields calls fn for every confkey field of val, embedded structs are walked as
+// part of val while nested structs that are not inline are walked with their keys
+// prefixed by the parent confkey like tls.ca
+func walkFields(val reflect.Value, prefix string, fn func(key string, field reflect.StructField, v reflect.Value) error) error {
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+		fv := val.Field(i)
+
+		key, ok := confkeyTag(field)
+
+		if !ok && field.Anonymous && fv.Kind() == reflect.Struct {
+			err := walkFields(fv, prefix, fn)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		if fv.Kind() == reflect.Struct && !isLeafStruct(fv.Type()) && field.Tag.Get("type") != "inline" {
+			err := walkFields(fv, prefix+key+".", fn)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := fn(prefix+key, field, fv)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// marshalValue renders a single field in the form SetStructFieldWithKey accepts
+func marshalValue(val reflect.Value, field reflect.StructField) (string, error) {
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return "", nil
+		}
+
+		val = val.Elem()
+	}
+
+	if val.Type() == reflect.TypeOf(time.Duration(0)) {
+		return marshalDuration(time.Duration(val.Int()), field)
+	}
+
+	if t, ok := val.Interface().(time.Time); ok {
+		return t.Format(timeLayout(field)), nil
+	}
+
+	if u, ok := val.Interface().(url.URL); ok {
+		return u.String(), nil
+	}
+
+	switch val.Kind() {
+	case reflect.Slice:
+		sep := listSeparator(field)
+		if sep == "" {
+			sep = ","
+		}
+
+		items := []string{}
+		for i := 0; i < val.Len(); i++ {
+			item := fmt.Sprint(val.Index(i).Interface())
+
+			// comma_split items are quoted when they would not split back into the same item
+			if field.Tag.Get("type") == "comma_split" && (strings.Contains(item, sep) || strings.Contains(item, `"`) || item != strings.TrimSpace(item)) {
+				item = `"` + strings.ReplaceAll(item, `"`, `""`) + `"`
+			}
+
+			items = append(items, item)
+		}
+
+		return strings.Join(items, sep), nil
+
+	case reflect.Map:
+		// sorted so the output is deterministic
+		keys := val.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		pairs := []string{}
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", k.Interface(), val.MapIndex(k).Interface()))
+		}
+
+		return strings.Join(pairs, ","), nil
+
+	case reflect.Int, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10), nil
+
+	case reflect.Uint, reflect.Uint64:
+		return strconv.FormatUint(val.Uint(), 10), nil
+
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'g', -1, val.Type().Bits()), nil
+
+	case reflect.Bool:
+		return marshalBool(val.Bool(), field)
+
+	case reflect.String:
+		return val.String(), nil
+
+	case reflect.Struct:
+		inner, err := marshal(val.Interface())
+		if err != nil {
+			return "", err
+		}
+
+		keys := []string{}
+		for k := range inner {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := []string{}
+		for _, k := range keys {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", k, inner[k]))
+		}
+
+		return strings.Join(pairs, ","), nil
+	}
+
+	return fmt.Sprint(val.Interface()), nil
+}
+
+// marshalDuration renders a duration using Duration.String() or in the unit set using the marshal_unit tag
+func marshalDuration(d time.Duration, field reflect.StructField) (string, error) {
+	u, ok := field.Tag.Lookup("marshal_unit")
+	if !ok {
+		return d.String(), nil
+	}
+
+	unit, ok := durationUnits[u]
+	if !ok {
+		return "", fmt.Errorf("invalid marshal_unit '%s' for %s", u, field.Name)
+	}
+
+	return strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64) + u, nil
+}
+
+// marshalBool renders a bool as true or false or using the words set in the bool_words tag
+func marshalBool(b bool, field reflect.StructField) (string, error) {
+	words, ok := field.Tag.Lookup("bool_words")
+	if !ok {
+		return strconv.FormatBool(b), nil
+	}
+
+	parts := strings.Split(words, ",")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid bool_words '%s' for %s, expected true,false words", words, field.Name)
+	}
+
+	if b {
+		return strings.TrimSpace(parts[0]), nil
+	}
+
+	return strings.TrimSpace(parts[1]), nil
+}
@@ -0,0 +1,365 @@
+package confkey
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type MarshalUnitData struct {
+	Seconds time.Duration `confkey:"seconds" type:"duration" marshal_unit:"s"`
+	Minutes time.Duration `confkey:"minutes" type:"duration" marshal_unit:"m"`
+	Default time.Duration `confkey:"default" type:"duration"`
+}
+
+type BadMarshalUnitData struct {
+	Bad time.Duration `confkey:"bad" type:"duration" marshal_unit:"d"`
+}
+
+type MapData struct {
+	Labels map[string]string `confkey:"labels" type:"kv_split"`
+}
+
+type BoolWordsData struct {
+	Enabled bool `confkey:"enabled" bool_words:"yes,no"`
+	Debug   bool `confkey:"debug"`
+}
+
+type BadBoolWordsData struct {
+	Enabled bool `confkey:"enabled" bool_words:"yes"`
+}
+
+type MarshalTimeData struct {
+	Day time.Time `confkey:"day" type:"time" format:"2006-01-02"`
+}
+
+type SecretData struct {
+	User     string `confkey:"user"`
+	Password string `confkey:"password" secret:"true"`
+	Token    string `confkey:"tls.token" secret:"true"`
+}
+
+type TextData struct {
+	Loglevel string            `confkey:"loglevel"`
+	Servers  []string          `confkey:"servers" type:"comma_split"`
+	Libdir   []string          `confkey:"libdir"`
+	Interval time.Duration     `confkey:"interval" type:"duration"`
+	Debug    bool              `confkey:"debug"`
+	CA       string            `confkey:"tls.ca"`
+	Verify   bool              `confkey:"tls.verify"`
+	Port     int               `confkey:"http.port"`
+	Labels   map[string]string `confkey:"labels" type:"kv_split"`
+}
+
+var _ = Describe("Marshal", func() {
+	var d TestData
+
+	BeforeEach(func() {
+		d = TestData{}
+	})
+
+	var _ = Describe("MarshalMap", func() {
+		It("Should render all the keys", func() {
+			d.PlainString = "hello"
+			d.CommaSplit = []string{"a", "b"}
+			d.ColonSplit = []string{"/a", "/b"}
+			d.PathSplit = []string{"/c", "/d"}
+			d.Int = 10
+			d.Int64 = 20
+			d.Bool = true
+			d.T = time.Hour
+
+			m, err := MarshalMap(&d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m["plain_string"]).To(Equal("hello"))
+			Expect(m["comma_split"]).To(Equal("a,b"))
+			Expect(m["colon_split"]).To(Equal("/a:/b"))
+			Expect(m["path_split"]).To(Equal("/c" + string(os.PathListSeparator) + "/d"))
+			Expect(m["int"]).To(Equal("10"))
+			Expect(m["int64"]).To(Equal("20"))
+			Expect(m["bool"]).To(Equal("true"))
+			Expect(m["interval"]).To(Equal("1h0m0s"))
+		})
+
+		It("Should round trip", func() {
+			d.CommaSplit = []string{"a", "b"}
+			d.PathSplit = []string{"/c", "/d"}
+			d.StringEnum = "info"
+			d.T = 90 * time.Second
+			d.Ratio = 0.5
+
+			m, err := MarshalMap(d)
+			Expect(err).ToNot(HaveOccurred())
+
+			n := TestData{}
+			Expect(SetFields(&n, map[string]string{
+				"comma_split": m["comma_split"],
+				"path_split":  m["path_split"],
+				"loglevel":    m["loglevel"],
+				"interval":    m["interval"],
+				"ratio":       m["ratio"],
+			})).To(Succeed())
+			Expect(n.CommaSplit).To(Equal(d.CommaSplit))
+			Expect(n.PathSplit).To(Equal(d.PathSplit))
+			Expect(n.StringEnum).To(Equal(d.StringEnum))
+			Expect(n.T).To(Equal(d.T))
+			Expect(n.Ratio).To(Equal(d.Ratio))
+		})
+
+		It("Should render times using their format", func() {
+			m, err := MarshalMap(MarshalTimeData{Day: time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal(map[string]string{"day": "2020-03-04"}))
+		})
+
+		It("Should redact secrets", func() {
+			sd := SecretData{User: "bob", Password: "s3cret", Token: "t0ken"}
+
+			m, err := MarshalMap(&sd)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal(map[string]string{"user": "bob", "password": Redacted, "tls.token": Redacted}))
+			Expect(sd.Password).To(Equal("s3cret"))
+		})
+
+		It("Should require a struct", func() {
+			_, err := MarshalMap(nil)
+			Expect(err).To(MatchError("target is required"))
+		})
+	})
+
+	var _ = Describe("String", func() {
+		It("Should render sorted pairs with secrets redacted", func() {
+			sd := SecretData{User: "bob", Password: "s3cret", Token: "t0ken"}
+			Expect(String(sd)).To(Equal("password=**** tls.token=**** user=bob"))
+		})
+
+		It("Should report invalid targets", func() {
+			Expect(String(nil)).To(Equal("invalid config: target is required"))
+		})
+	})
+
+	var _ = Describe("DriftReport", func() {
+		It("Should report drifted keys", func() {
+			d.StringEnum = "info"
+			d.T = time.Hour
+			d.CommaSplit = []string{"a", "b"}
+
+			baseline, err := marshal(&d)
+			Expect(err).ToNot(HaveOccurred())
+
+			drift, err := DriftReport(&d, baseline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(drift).To(BeEmpty())
+
+			d.StringEnum = "debug"
+			d.CommaSplit = []string{"a", "b", "c"}
+			baseline["old_key"] = "1"
+
+			drift, err = DriftReport(&d, baseline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(drift).To(Equal(map[string]string{
+				"loglevel":    "debug",
+				"comma_split": "a,b,c",
+				"old_key":     DriftRemoved,
+			}))
+		})
+
+		It("Should not report secrets", func() {
+			sd := SecretData{User: "bob", Password: "s3cret"}
+
+			baseline, err := MarshalMap(&sd)
+			Expect(err).ToNot(HaveOccurred())
+
+			sd.User = "alice"
+			sd.Password = "other"
+
+			drift, err := DriftReport(&sd, baseline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(drift).To(Equal(map[string]string{"user": "alice"}))
+		})
+
+		It("Should render values", func() {
+			d.T = 90 * time.Second
+			d.Int = 10
+			d.Bool = true
+
+			drift, err := DriftReport(d, map[string]string{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(drift["interval"]).To(Equal("1m30s"))
+			Expect(drift["int"]).To(Equal("10"))
+			Expect(drift["bool"]).To(Equal("true"))
+		})
+	})
+	var _ = Describe("Diff", func() {
+		It("Should report changed keys", func() {
+			a := TextData{Loglevel: "info", Servers: []string{"a"}, Port: 80}
+			b := a
+			b.Loglevel = "debug"
+			b.Servers = []string{"a", "b"}
+
+			changes, err := Diff(a, &b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(Equal(map[string]struct{ Old, New string }{
+				"loglevel": {"info", "debug"},
+				"servers":  {"a", "a,b"},
+			}))
+
+			changes, err = Diff(&a, &a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("Should redact changed secrets", func() {
+			changes, err := Diff(SecretData{Password: "old"}, SecretData{Password: "new"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(Equal(map[string]struct{ Old, New string }{"password": {Redacted, Redacted}}))
+		})
+
+		It("Should require the same types", func() {
+			_, err := Diff(SecretData{}, TextData{})
+			Expect(err).To(MatchError("cannot compare confkey.SecretData with confkey.TextData"))
+		})
+	})
+
+	var _ = Describe("marshal", func() {
+		It("Should support marshal_unit", func() {
+			m, err := marshal(MarshalUnitData{Seconds: time.Hour, Minutes: 90 * time.Second, Default: time.Hour})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m).To(Equal(map[string]string{
+				"seconds": "3600s",
+				"minutes": "1.5m",
+				"default": "1h0m0s",
+			}))
+
+			_, err = marshal(BadMarshalUnitData{Bad: time.Hour})
+			Expect(err).To(MatchError("invalid marshal_unit 'd' for Bad"))
+		})
+	})
+	var _ = Describe("quoted lists", func() {
+		It("Should round trip items containing separators", func() {
+			q := TestData{CommaSplit: []string{"a,b", `say "hi"`, " c", "d"}}
+
+			out, err := marshal(&q)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out["comma_split"]).To(Equal(`"a,b","say ""hi"""," c",d`))
+
+			n := TestData{}
+			err = SetFields(&n, map[string]string{"comma_split": out["comma_split"]})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n.CommaSplit).To(Equal(q.CommaSplit))
+		})
+	})
+	var _ = Describe("maps", func() {
+		It("Should round trip map fields", func() {
+			m := MapData{}
+			err := SetFields(&m, map[string]string{"labels": "zone=eu, dc = one,app=web"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.Labels).To(Equal(map[string]string{"zone": "eu", "dc": "one", "app": "web"}))
+
+			out, err := marshal(&m)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal(map[string]string{"labels": "app=web,dc=one,zone=eu"}))
+
+			n := MapData{}
+			err = SetFields(&n, out)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(m))
+
+			again, err := marshal(&n)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(again).To(Equal(out))
+		})
+
+		It("Should detect invalid items", func() {
+			m := MapData{}
+			err := SetStructFieldWithKey(&m, "labels", "zone")
+			Expect(err).To(MatchError("invalid item 'zone' for key 'labels', expected key=value"))
+		})
+	})
+	var _ = Describe("bools", func() {
+		It("Should support bool_words", func() {
+			b := BoolWordsData{}
+			err := SetFields(&b, map[string]string{"enabled": "yes", "debug": "y"})
+			Expect(err).ToNot(HaveOccurred())
+
+			out, err := marshal(&b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal(map[string]string{"enabled": "yes", "debug": "true"}))
+
+			b.Enabled = false
+			out, err = marshal(&b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out["enabled"]).To(Equal("no"))
+
+			_, err = marshal(BadBoolWordsData{})
+			Expect(err).To(MatchError("invalid bool_words 'yes' for Enabled, expected true,false words"))
+		})
+	})
+	var _ = Describe("MarshalText", func() {
+		It("Should render sorted keys grouped in sections", func() {
+			t := TextData{
+				Loglevel: "info",
+				Servers:  []string{"s1", "s2"},
+				Libdir:   []string{"/one", "/two"},
+				Interval: 90 * time.Second,
+				CA:       "/ca.pem",
+				Verify:   true,
+				Port:     8080,
+			}
+
+			out, err := MarshalText(&t)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(out)).To(Equal(`debug = false
+interval = 1m30s
+libdir = /one
+libdir = /two
+loglevel = info
+servers = s1,s2
+
+[http]
+port = 8080
+
+[tls]
+ca = /ca.pem
+verify = true
+`))
+		})
+
+		It("Should round trip through ParseConfig", func() {
+			t := TextData{
+				Loglevel: "info",
+				Servers:  []string{"s1", "s2"},
+				Libdir:   []string{"/one", "/two"},
+				Interval: time.Hour,
+				Debug:    true,
+				CA:       "/ca.pem",
+				Port:     8080,
+				Labels:   map[string]string{"a": "1", "b": "2"},
+			}
+
+			out, err := MarshalText(t)
+			Expect(err).ToNot(HaveOccurred())
+
+			n := TextData{}
+			Expect(ParseConfig(&n, bytes.NewReader(out))).To(Succeed())
+			Expect(n).To(Equal(t))
+		})
+
+		It("Should skip nil pointers", func() {
+			name := "choria"
+			p := PointerData{Name: &name}
+
+			out, err := MarshalText(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(out)).To(Equal("name = choria\n"))
+
+			n := PointerData{}
+			Expect(ParseConfig(&n, bytes.NewReader(out))).To(Succeed())
+			Expect(n.Port).To(BeNil())
+			Expect(*n.Name).To(Equal("choria"))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package confkey
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Option configures the behavior of functions like SetFields
+type Option func(*options)
+
+type options struct {
+	prefix    string
+	envPrefix string
+	strict    bool
+
+	// skipEnv disables the environment override when setting fields
+	skipEnv bool
+
+	// appendList adds items to slices rather than replacing them
+	appendList bool
+
+	// foldCase matches keys against confkeys ignoring case when no exact match exists
+	foldCase bool
+}
+
+// WithKeyPrefix strips prefix from incoming keys before they are matched against confkeys
+func WithKeyPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithEnvPrefix derives environment variable names for fields without an environment tag
+// by combining prefix with the upper cased confkey, dots and dashes becomes underscores
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithStrict reports keys that do not match what the other options expect, like keys lacking the prefix set using WithKeyPrefix,
+// and fields with a type tag that is not understood for their kind
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// key determines the confkey to use for an incoming key
+func (o *options) key(k string) (string, error) {
+	if o.prefix == "" {
+		return k, nil
+	}
+
+	if strings.HasPrefix(k, o.prefix) {
+		return strings.TrimPrefix(k, o.prefix), nil
+	}
+
+	if o.strict {
+		return "", fmt.Errorf("key '%s' does not have the required prefix '%s'", k, o.prefix)
+	}
+
+	return k, nil
+}
+
+// envName determines the environment variable for a field, "" when none applies
+func (o *options) envName(field reflect.StructField, key string) string {
+	if env, ok := field.Tag.Lookup("environment"); ok {
+		return env
+	}
+
+	if o.envPrefix == "" || key == "" {
+		return ""
+	}
+
+	return o.envPrefix + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(key))
+}
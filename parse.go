@@ -0,0 +1,107 @@
+package confkey
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseConfig reads a simple config file made up of key = value lines and sets each
+// key on target using SetStructFieldWithKey. Blank lines and lines starting with #
+// or ; are ignored, keys may be repeated and are applied in the order they appear
+// with repeated list keys adding to the list.
+//
+// Keys following a [section] header are prefixed with the section name, a key ca
+// in the section tls is set as tls.ca
+func ParseConfig(target interface{}, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	prefix := ""
+	seen := make(map[string]bool)
+
+	for scanner.Scan() {
+		lineno++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			prefix = strings.TrimSpace(line[1 : len(line)-1])
+			if prefix != "" {
+				prefix += "."
+			}
+
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("line %d: expected key = value", lineno)
+		}
+
+		key := prefix + strings.TrimSpace(kv[0])
+
+		// repeated keys add to lists like multiple libdir lines would
+		o := newOptions()
+		o.appendList = seen[key]
+
+		err := setStructField(target, key, strings.TrimSpace(kv[1]), o)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", lineno, err)
+		}
+
+		seen[key] = true
+	}
+
+	return scanner.Err()
+}
+
+// LoadFiles applies the defaults to target and then parses each file in order using
+// ParseConfig, later files override earlier ones and the result is validated at the end
+func LoadFiles(target interface{}, paths ...string) error {
+	return loadFiles(target, false, paths...)
+}
+
+// LoadFilesOptional behaves like LoadFiles but files that do not exist are skipped
+func LoadFilesOptional(target interface{}, paths ...string) error {
+	return loadFiles(target, true, paths...)
+}
+
+func loadFiles(target interface{}, optional bool, paths ...string) error {
+	err := SetStructDefaults(target)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		err = loadFile(target, path)
+		if err != nil {
+			if optional && os.IsNotExist(err) {
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return Validate(target)
+}
+
+func loadFile(target interface{}, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = ParseConfig(target, f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	return nil
+}
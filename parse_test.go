@@ -0,0 +1,99 @@
+package confkey
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type LayeredData struct {
+	Loglevel string   `confkey:"loglevel" default:"warn" validate:"enum=debug,info,warn,error"`
+	Mode     string   `confkey:"mode" default:"server"`
+	Servers  []string `confkey:"servers" type:"comma_split"`
+	Port     int      `confkey:"port" default:"8080"`
+	Libdir   []string `confkey:"libdir" type:"colon_split" default:"/usr/lib"`
+}
+
+var _ = Describe("Parse", func() {
+	var (
+		td  string
+		err error
+	)
+
+	BeforeEach(func() {
+		td, err = ioutil.TempDir("", "confkey")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(td)
+	})
+
+	write := func(name string, content string) string {
+		path := filepath.Join(td, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	var _ = Describe("ParseConfig", func() {
+		It("Should parse key value lines", func() {
+			d := LayeredData{}
+			err := ParseConfig(&d, strings.NewReader("# comment\n\nloglevel = debug\n; other comment\nservers = a, b\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Loglevel).To(Equal("debug"))
+			Expect(d.Servers).To(Equal([]string{"a", "b"}))
+		})
+
+		It("Should add repeated list keys together", func() {
+			d := LayeredData{}
+			Expect(SetStructDefaults(&d)).To(Succeed())
+			Expect(ParseConfig(&d, strings.NewReader("libdir = /one:/two\nlibdir = /three\n"))).To(Succeed())
+			Expect(d.Libdir).To(Equal([]string{"/one", "/two", "/three"}))
+		})
+
+		It("Should report errors with line numbers", func() {
+			d := LayeredData{}
+			Expect(ParseConfig(&d, strings.NewReader("loglevel = debug\nport\n"))).To(MatchError("line 2: expected key = value"))
+			Expect(ParseConfig(&d, strings.NewReader("port = x\n"))).To(MatchError(`line 1: strconv.ParseInt: parsing "x": invalid syntax`))
+		})
+	})
+
+	var _ = Describe("LoadFiles", func() {
+		It("Should layer files in order", func() {
+			defaults := write("defaults.cfg", "loglevel = info\nservers = a, b\n")
+			site := write("site.cfg", "mode = client\nservers = c\n")
+			local := write("local.cfg", "loglevel = debug\n")
+
+			d := LayeredData{}
+			err := LoadFiles(&d, defaults, site, local)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(Equal(LayeredData{Loglevel: "debug", Mode: "client", Servers: []string{"c"}, Port: 8080, Libdir: []string{"/usr/lib"}}))
+		})
+
+		It("Should fail on missing files unless optional", func() {
+			site := write("site.cfg", "mode = client\n")
+			missing := filepath.Join(td, "missing.cfg")
+
+			d := LayeredData{}
+			err := LoadFiles(&d, site, missing)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+
+			d = LayeredData{}
+			err = LoadFilesOptional(&d, site, missing)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d.Mode).To(Equal("client"))
+		})
+
+		It("Should report errors with the file name", func() {
+			bad := write("bad.cfg", "loglevel = fatal\n")
+
+			d := LayeredData{}
+			err := LoadFiles(&d, bad)
+			Expect(err).To(MatchError(bad + ": line 1: Loglevel enum validation failed: 'fatal' is not in the allowed list: debug, info, warn, error"))
+		})
+	})
+})
@@ -0,0 +1,332 @@
+package confkey
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/choria-io/go-validator/enum"
+)
+
+// FieldParser parses the string value of a single key into the value to store in its field
+type FieldParser func(string) (interface{}, error)
+
+type fieldParserKey struct {
+	t   reflect.Type
+	key string
+}
+
+// PreProcessor transforms the string value of key before it is converted to the field type
+type PreProcessor func(key string, value string) (string, error)
+
+var (
+	dynamicEnums  = make(map[string]func() []string)
+	fieldParsers  = make(map[fieldParserKey]FieldParser)
+	converters    = make(map[reflect.Type]FieldParser)
+	preProcessors = []PreProcessor{}
+	structChecks  = make(map[reflect.Type][]func(interface{}) error)
+	patterns      = make(map[string]*regexp.Regexp)
+	writableWarn  func(error)
+	truthyWords   []string
+	falsyWords    []string
+
+	mu sync.Mutex
+)
+
+// RegisterFieldParser registers a parser used by SetStructFieldWithKey for the field
+// tagged with key in structs of type structType, the built in type conversions are
+// skipped for that field and the parsed value is assigned to it instead
+func RegisterFieldParser(structType reflect.Type, key string, fn FieldParser) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	k := fieldParserKey{structType, key}
+
+	if fn == nil {
+		delete(fieldParsers, k)
+		return
+	}
+
+	fieldParsers[k] = fn
+}
+
+func fieldParser(structType reflect.Type, key string) (FieldParser, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fn, ok := fieldParsers[fieldParserKey{structType, key}]
+
+	return fn, ok
+}
+
+// RegisterConverter registers a converter used by SetStructFieldWithKey for every field
+// of type t, parsers registered using RegisterFieldParser for a specific key take precedence
+func RegisterConverter(t reflect.Type, fn FieldParser) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if fn == nil {
+		delete(converters, t)
+		return
+	}
+
+	converters[t] = fn
+}
+
+func converter(t reflect.Type) (FieldParser, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fn, ok := converters[t]
+
+	return fn, ok
+}
+
+// RegisterBoolWords registers words that bool fields accept in addition to the built in
+// ones like yes and no, words are matched ignoring case and every call replaces the
+// previously registered words, passing nil for both restores the defaults
+func RegisterBoolWords(truthy []string, falsy []string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	truthyWords = truthy
+	falsyWords = falsy
+}
+
+// boolWord checks the words registered with RegisterBoolWords for s
+func boolWord(s string) (value bool, found bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, w := range truthyWords {
+		if strings.EqualFold(w, s) {
+			return true, true
+		}
+	}
+
+	for _, w := range falsyWords {
+		if strings.EqualFold(w, s) {
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+// setWithFieldParser sets field using a parser registered with RegisterFieldParser or RegisterConverter
+func setWithFieldParser(field reflect.Value, key string, fn FieldParser, value string) error {
+	parsed, err := fn(value)
+	if err != nil {
+		return err
+	}
+
+	pv := reflect.ValueOf(parsed)
+	if !pv.IsValid() {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if !pv.Type().AssignableTo(field.Type()) {
+		if !pv.Type().ConvertibleTo(field.Type()) {
+			return fmt.Errorf("parser for key '%s' returned %s which cannot be assigned to %s", key, pv.Type(), field.Type())
+		}
+
+		pv = pv.Convert(field.Type())
+	}
+
+	field.Set(pv)
+
+	return nil
+}
+
+// RegisterEnum registers a function that supplies the allowed values for the field
+// tagged with key, Validate will check the field against the values returned at the
+// time of validation
+func RegisterEnum(key string, valuesFn func() []string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if valuesFn == nil {
+		delete(dynamicEnums, key)
+		return
+	}
+
+	dynamicEnums[key] = valuesFn
+}
+
+// RegisterPreProcessor registers a function that is called with every string value
+// before it is converted to the field type, processors are called in the order they
+// were registered and an error from any of them aborts setting the field, passing nil
+// removes all the registered processors
+func RegisterPreProcessor(fn PreProcessor) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if fn == nil {
+		preProcessors = []PreProcessor{}
+		return
+	}
+
+	preProcessors = append(preProcessors, fn)
+}
+
+// preProcess passes value through all registered pre processors
+func preProcess(key string, value string) (string, error) {
+	mu.Lock()
+	processors := make([]PreProcessor, len(preProcessors))
+	copy(processors, preProcessors)
+	mu.Unlock()
+
+	var err error
+
+	for _, fn := range processors {
+		value, err = fn(key, value)
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", key, err)
+		}
+	}
+
+	return value, nil
+}
+
+// RegisterStructValidator registers fn to be called with the target at the end of
+// Validate and ValidateAll for structs of the same type as target, used for rules
+// spanning several fields. Validators are called in the order they were registered
+// and passing a nil fn removes all the validators for the type
+func RegisterStructValidator(target interface{}, fn func(interface{}) error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	t := reflect.TypeOf(target)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if fn == nil {
+		delete(structChecks, t)
+		return
+	}
+
+	structChecks[t] = append(structChecks[t], fn)
+}
+
+// structValidate calls the validators registered for the type of target
+func structValidate(target interface{}, all bool) []error {
+	t := reflect.TypeOf(target)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	mu.Lock()
+	checks := make([]func(interface{}) error, len(structChecks[t]))
+	copy(checks, structChecks[t])
+	mu.Unlock()
+
+	errs := []error{}
+
+	for _, fn := range checks {
+		err := fn(target)
+		if err != nil {
+			errs = append(errs, err)
+
+			if !all {
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// SetWritableWarner downgrades validate:"writable" failures to warnings passed to fn
+// rather than failing Validate, useful in read-only test environments. A nil fn
+// restores the default behavior
+func SetWritableWarner(fn func(error)) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	writableWarn = fn
+}
+
+func writableWarner() (func(error), bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return writableWarn, writableWarn != nil
+}
+
+func dynamicEnum(key string) (func() []string, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fn, ok := dynamicEnums[key]
+
+	return fn, ok
+}
+
+// validateDynamicEnums checks fields against enums registered using RegisterEnum
+func validateDynamicEnums(target interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	st := val.Type()
+
+	for i := 0; i <= st.NumField()-1; i++ {
+		field := st.Field(i)
+
+		key, ok := confkeyTag(field)
+		if !ok {
+			continue
+		}
+
+		fn, ok := dynamicEnum(key)
+		if !ok {
+			continue
+		}
+
+		var err error
+		valid := fn()
+
+		switch v := val.Field(i).Interface().(type) {
+		case string:
+			_, err = enum.ValidateString(v, valid)
+		case []string:
+			_, err = enum.ValidateSlice(v, valid)
+		default:
+			err = fmt.Errorf("cannot validate data of type %s for enums", val.Field(i).Kind())
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s enum validation failed: %s", field.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// compiledPattern compiles the pattern of a match tag, compiled patterns are cached
+func compiledPattern(pattern string) (*regexp.Regexp, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if re, ok := patterns[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns[pattern] = re
+
+	return re, nil
+}
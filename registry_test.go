@@ -0,0 +1,286 @@
+package confkey
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type OtherData struct {
+	Int int `confkey:"int"`
+}
+
+type LogLevel int
+
+type SizeBytes int64
+
+type ConverterData struct {
+	Level LogLevel  `confkey:"level"`
+	Size  SizeBytes `confkey:"size"`
+	Other LogLevel  `confkey:"other"`
+	Plain int       `confkey:"plain"`
+}
+
+type TLSData struct {
+	Cert string `confkey:"tls_cert"`
+	Key  string `confkey:"tls_key"`
+	Mode string `confkey:"tls_mode" validate:"enum=verify,none"`
+}
+
+var _ = Describe("Registry", func() {
+	var d TestData
+
+	BeforeEach(func() {
+		d = TestData{StringEnum: "warn"}
+	})
+
+	var _ = Describe("RegisterEnum", func() {
+		AfterEach(func() {
+			RegisterEnum("plain_string", nil)
+			RegisterEnum("comma_split", nil)
+		})
+
+		It("Should validate against the current values", func() {
+			plugins := []string{"one", "two"}
+			RegisterEnum("plain_string", func() []string { return plugins })
+
+			d.PlainString = "three"
+			Expect(Validate(d)).To(MatchError("PlainString enum validation failed: 'three' is not in the allowed list: one, two"))
+
+			plugins = append(plugins, "three")
+			Expect(Validate(d)).ToNot(HaveOccurred())
+		})
+
+		It("Should support slices", func() {
+			RegisterEnum("comma_split", func() []string { return []string{"one", "two"} })
+
+			d.CommaSplit = []string{"one", "three"}
+			Expect(Validate(&d)).To(MatchError("CommaSplit enum validation failed: 'three' is not in the allowed list: one, two"))
+		})
+	})
+	var _ = Describe("RegisterFieldParser", func() {
+		AfterEach(func() {
+			RegisterFieldParser(reflect.TypeOf(d), "int", nil)
+		})
+
+		It("Should use the parser for the registered key only", func() {
+			RegisterFieldParser(reflect.TypeOf(&d), "int", func(v string) (interface{}, error) {
+				switch v {
+				case "one":
+					return 1, nil
+				case "many":
+					return int64(100), nil
+				case "bad":
+					return "bad", nil
+				}
+
+				return nil, errors.New("unknown number " + v)
+			})
+
+			Expect(SetStructFieldWithKey(&d, "int", "one")).ToNot(HaveOccurred())
+			Expect(d.Int).To(Equal(1))
+
+			Expect(SetStructFieldWithKey(&d, "int", "many")).ToNot(HaveOccurred())
+			Expect(d.Int).To(Equal(100))
+
+			Expect(SetStructFieldWithKey(&d, "int", "2")).To(MatchError("unknown number 2"))
+			Expect(SetStructFieldWithKey(&d, "int", "bad")).To(MatchError("parser for key 'int' returned string which cannot be assigned to int"))
+
+			Expect(SetStructFieldWithKey(&d, "title_string", "one")).ToNot(HaveOccurred())
+			Expect(d.TitleString).To(Equal("One"))
+
+			other := OtherData{}
+			Expect(SetStructFieldWithKey(&other, "int", "2")).ToNot(HaveOccurred())
+			Expect(other.Int).To(Equal(2))
+		})
+
+		It("Should validate the parsed value", func() {
+			RegisterFieldParser(reflect.TypeOf(d), "plain_string", func(v string) (interface{}, error) {
+				return strings.ToUpper(v), nil
+			})
+			defer RegisterFieldParser(reflect.TypeOf(d), "plain_string", nil)
+
+			Expect(SetStructFieldWithKey(&d, "plain_string", "x")).ToNot(HaveOccurred())
+			Expect(d.PlainString).To(Equal("X"))
+			Expect(SetStructFieldWithKey(&d, "plain_string", "a > b")).To(MatchError("PlainString shellsafe validation failed: may not contain '>'"))
+		})
+	})
+
+	var _ = Describe("RegisterConverter", func() {
+		AfterEach(func() {
+			RegisterConverter(reflect.TypeOf(LogLevel(0)), nil)
+			RegisterConverter(reflect.TypeOf(SizeBytes(0)), nil)
+			RegisterFieldParser(reflect.TypeOf(ConverterData{}), "other", nil)
+		})
+
+		It("Should convert every field of the registered type", func() {
+			RegisterConverter(reflect.TypeOf(LogLevel(0)), func(v string) (interface{}, error) {
+				switch v {
+				case "debug":
+					return LogLevel(1), nil
+				case "info":
+					return LogLevel(2), nil
+				}
+
+				return nil, errors.New("unknown level " + v)
+			})
+			RegisterConverter(reflect.TypeOf(SizeBytes(0)), func(v string) (interface{}, error) {
+				return 1024, nil
+			})
+
+			cd := ConverterData{}
+			Expect(SetStructFieldWithKey(&cd, "level", "info")).To(Succeed())
+			Expect(cd.Level).To(Equal(LogLevel(2)))
+
+			Expect(SetStructFieldWithKey(&cd, "level", "fatal")).To(MatchError("unknown level fatal"))
+			Expect(cd.Level).To(Equal(LogLevel(2)))
+
+			Expect(SetStructFieldWithKey(&cd, "size", "1k")).To(Succeed())
+			Expect(cd.Size).To(Equal(SizeBytes(1024)))
+
+			Expect(SetStructFieldWithKey(&cd, "plain", "10")).To(Succeed())
+			Expect(cd.Plain).To(Equal(10))
+		})
+
+		It("Should prefer field parsers", func() {
+			RegisterConverter(reflect.TypeOf(LogLevel(0)), func(v string) (interface{}, error) {
+				return LogLevel(1), nil
+			})
+			RegisterFieldParser(reflect.TypeOf(ConverterData{}), "other", func(v string) (interface{}, error) {
+				return LogLevel(5), nil
+			})
+
+			cd := ConverterData{}
+			Expect(SetStructFieldWithKey(&cd, "level", "x")).To(Succeed())
+			Expect(SetStructFieldWithKey(&cd, "other", "x")).To(Succeed())
+			Expect(cd.Level).To(Equal(LogLevel(1)))
+			Expect(cd.Other).To(Equal(LogLevel(5)))
+		})
+	})
+
+	var _ = Describe("RegisterBoolWords", func() {
+		AfterEach(func() {
+			RegisterBoolWords(nil, nil)
+		})
+
+		It("Should accept the registered words", func() {
+			RegisterBoolWords([]string{"on", "enabled"}, []string{"off", "disabled"})
+
+			Expect(SetStructFieldWithKey(&d, "bool", "ON")).To(Succeed())
+			Expect(d.Bool).To(BeTrue())
+
+			Expect(SetStructFieldWithKey(&d, "bool", "disabled")).To(Succeed())
+			Expect(d.Bool).To(BeFalse())
+
+			Expect(SetStructFieldWithKey(&d, "bool", "enabled")).To(Succeed())
+			Expect(d.Bool).To(BeTrue())
+
+			Expect(SetStructFieldWithKey(&d, "bool", "no")).To(Succeed())
+			Expect(d.Bool).To(BeFalse())
+		})
+
+		It("Should restore the defaults", func() {
+			RegisterBoolWords([]string{"on"}, []string{"off"})
+			RegisterBoolWords(nil, nil)
+
+			_, err := strToBool("on")
+			Expect(err).To(HaveOccurred())
+
+			b, err := strToBool("yes")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b).To(BeTrue())
+		})
+	})
+
+	var _ = Describe("RegisterStructValidator", func() {
+		AfterEach(func() {
+			RegisterStructValidator(TLSData{}, nil)
+		})
+
+		It("Should check rules spanning fields", func() {
+			RegisterStructValidator(&TLSData{}, func(t interface{}) error {
+				if t.(*TLSData).Cert != "" && t.(*TLSData).Key == "" {
+					return errors.New("tls_key is required when tls_cert is set")
+				}
+
+				return nil
+			})
+			RegisterStructValidator(TLSData{}, func(t interface{}) error {
+				return errors.New("always fails")
+			})
+
+			td := TLSData{Cert: "cert.pem", Mode: "verify"}
+			Expect(Validate(&td)).To(MatchError("tls_key is required when tls_cert is set"))
+			Expect(ValidateAll(&td)).To(Equal([]error{errors.New("tls_key is required when tls_cert is set"), errors.New("always fails")}))
+
+			td.Key = "key.pem"
+			Expect(Validate(&td)).To(MatchError("always fails"))
+
+			RegisterStructValidator(TLSData{}, nil)
+			Expect(Validate(&td)).To(Succeed())
+		})
+
+		It("Should run after the field validations", func() {
+			called := false
+			RegisterStructValidator(TLSData{}, func(interface{}) error {
+				called = true
+				return nil
+			})
+
+			td := TLSData{Cert: "cert.pem", Mode: "other"}
+			Expect(Validate(&td)).To(MatchError("Mode enum validation failed: 'other' is not in the allowed list: verify, none"))
+			Expect(called).To(BeFalse())
+		})
+	})
+
+	var _ = Describe("RegisterPreProcessor", func() {
+		AfterEach(func() {
+			RegisterPreProcessor(nil)
+		})
+
+		It("Should remove processors when given nil", func() {
+			RegisterPreProcessor(func(key string, value string) (string, error) {
+				return "processed", nil
+			})
+			RegisterPreProcessor(nil)
+
+			Expect(SetStructFieldWithKey(&d, "plain_string", "hello")).To(Succeed())
+			Expect(d.PlainString).To(Equal("hello"))
+		})
+
+		It("Should process every value", func() {
+			seen := []string{}
+
+			RegisterPreProcessor(func(key string, value string) (string, error) {
+				seen = append(seen, key)
+				return strings.ToUpper(value), nil
+			})
+
+			Expect(SetStructFieldWithKey(&d, "plain_string", "hello")).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&d, "comma_split", "a,b")).ToNot(HaveOccurred())
+			Expect(SetStructFieldWithKey(&d, "bool", "yes")).ToNot(HaveOccurred())
+
+			Expect(d.PlainString).To(Equal("HELLO"))
+			Expect(d.CommaSplit).To(Equal([]string{"A", "B"}))
+			Expect(d.Bool).To(BeTrue())
+			Expect(seen).To(Equal([]string{"plain_string", "comma_split", "bool"}))
+		})
+
+		It("Should abort on errors", func() {
+			RegisterPreProcessor(func(key string, value string) (string, error) {
+				if value == "deprecated" {
+					return "", errors.New("deprecated value")
+				}
+
+				return value, nil
+			})
+
+			d.PlainString = "x"
+			Expect(SetStructFieldWithKey(&d, "plain_string", "deprecated")).To(MatchError("plain_string: deprecated value"))
+			Expect(d.PlainString).To(Equal("x"))
+		})
+	})
+})